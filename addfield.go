@@ -0,0 +1,46 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// addFields are the -add-field key=value fields attached to every entry.
+var addFields listFlag
+
+func init() {
+	flag.Var(&addFields, "add-field", "attach a constant field to every entry, like env=staging, may be repeated")
+}
+
+// staticField is a field attached to every entry.
+type staticField struct {
+	key   string
+	value string
+}
+
+var staticFields []staticField
+
+// setStaticFields parses the -add-field fields.
+func setStaticFields() error {
+	staticFields = nil
+	for _, s := range addFields {
+		key, val, ok := strings.Cut(s, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("-add-field: %q is not key=value", s)
+		}
+		staticFields = append(staticFields, staticField{key, val})
+	}
+	return nil
+}
+
+// addStaticFields attaches the -add-field fields to the entry, a field the
+// entry already has is left alone.
+func addStaticFields(e *Entry) {
+	for _, f := range staticFields {
+		if _, ok := e.Fields[f.key]; !ok {
+			e.Fields[f.key] = f.value
+		}
+	}
+}
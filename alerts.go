@@ -0,0 +1,265 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-json"
+)
+
+var alertsFile = flag.String("alerts", "", "watch for the rules of the given yaml or toml alerts file while following")
+
+// alertRule fires its action when the entries matching all of its filters
+// reach the threshold within the window.
+type alertRule struct {
+	name      string
+	match     []valueRule
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	action    string
+	command   string // the shell command of the exec action.
+	url       string // where the webhook action posts to.
+
+	seen  []time.Time // when the matching entries within the window arrived.
+	quiet time.Time   // the rule does not fire again before this time.
+}
+
+// the actions a rule can take, besides always showing a warning.
+var alertActions = map[string]bool{"notify": true, "exec": true, "webhook": true, "bell": true}
+
+var alertRules []*alertRule
+
+// loadAlerts reads the rules of the alerts file, a yaml file unless it has a
+// .toml extension.
+//
+//	rules:
+//	  - name: database down
+//	    match: [level=error, "message=*database*"]
+//	    threshold: 5
+//	    window: 1m
+//	    action: webhook
+//	    url: https://hooks.example.com/glogv
+func loadAlerts(path string) error {
+	var doc any
+	if filepath.Ext(path) == ".toml" {
+		var m map[string]any
+		if _, err := toml.DecodeFile(path, &m); err != nil {
+			return fmt.Errorf("alerts %s: %w", path, err)
+		}
+		doc = m
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if doc, err = parseYAML(data); err != nil {
+			return fmt.Errorf("alerts %s: %w", path, err)
+		}
+	}
+
+	m, _ := doc.(map[string]any)
+	list, ok := m["rules"].([]any)
+	if !ok {
+		// toml decodes an array of tables to a slice of maps.
+		tables, _ := m["rules"].([]map[string]any)
+		for _, t := range tables {
+			list = append(list, t)
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("alerts %s: no rules", path)
+	}
+
+	alertRules = nil
+	for i, item := range list {
+		table, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("alerts %s: rule %d is not a table", path, i+1)
+		}
+		r, err := parseAlertRule(table)
+		if err != nil {
+			return fmt.Errorf("alerts %s: rule %d: %w", path, i+1, err)
+		}
+		alertRules = append(alertRules, r)
+	}
+	return nil
+}
+
+func parseAlertRule(t map[string]any) (*alertRule, error) {
+	str := func(key string) string {
+		if v, ok := t[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	r := &alertRule{
+		name:      str("name"),
+		threshold: 1,
+		window:    time.Minute,
+		action:    str("action"),
+		command:   str("command"),
+		url:       str("url"),
+	}
+	if r.name == "" {
+		return nil, fmt.Errorf("no name")
+	}
+
+	filters, ok := t["match"].([]any)
+	if !ok && t["match"] != nil {
+		filters = []any{t["match"]}
+	}
+	for _, f := range filters {
+		rule, err := parseRule("alerts match", fmt.Sprint(f))
+		if err != nil {
+			return nil, err
+		}
+		r.match = append(r.match, rule)
+	}
+
+	if s := str("threshold"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("threshold %q is not a positive number", s)
+		}
+		r.threshold = n
+	}
+	for _, d := range []struct {
+		key string
+		val *time.Duration
+	}{{"window", &r.window}, {"cooldown", &r.cooldown}} {
+		if s := str(d.key); s != "" {
+			v, err := time.ParseDuration(s)
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("%s %q is not a duration", d.key, s)
+			}
+			*d.val = v
+		}
+	}
+	if r.cooldown == 0 {
+		r.cooldown = r.window
+	}
+
+	switch {
+	case r.action == "":
+		r.action = "bell"
+	case !alertActions[r.action]:
+		return nil, fmt.Errorf("unknown action %q", r.action)
+	case r.action == "exec" && r.command == "":
+		return nil, fmt.Errorf("the exec action needs a command")
+	case r.action == "webhook" && r.url == "":
+		return nil, fmt.Errorf("the webhook action needs a url")
+	}
+	return r, nil
+}
+
+// checkAlerts counts the entry for every rule it matches, and fires those
+// that reach their threshold.
+func checkAlerts(e *Entry, now time.Time) {
+	for _, r := range alertRules {
+		if !r.matches(e) {
+			continue
+		}
+		r.seen = append(r.seen, now)
+		i := 0
+		for i < len(r.seen) && now.Sub(r.seen[i]) > r.window {
+			i++
+		}
+		r.seen = r.seen[i:]
+
+		if len(r.seen) >= r.threshold && !now.Before(r.quiet) {
+			r.fire(e, len(r.seen))
+			r.seen = r.seen[:0]
+			r.quiet = now.Add(r.cooldown)
+		}
+	}
+}
+
+func (r *alertRule) matches(e *Entry) bool {
+	for i := range r.match {
+		if !r.match[i].match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// fire shows the alert and takes the action of the rule.  commands and
+// webhooks run in the background so they do not hold up the output.
+func (r *alertRule) fire(e *Entry, count int) {
+	msg := fmt.Sprintf("alert %s: %d entries within %v", r.name, count, r.window)
+	printWarning(msg)
+
+	switch r.action {
+	case "bell":
+		os.Stderr.WriteString("\a")
+	case "notify":
+		go notify(r.name, msg)
+	case "exec":
+		cmd := shellCommand(r.command)
+		cmd.Env = append(os.Environ(),
+			"GLOGV_ALERT="+r.name,
+			"GLOGV_COUNT="+strconv.Itoa(count),
+			"GLOGV_LINE="+string(e.Raw))
+		go func() {
+			if err := cmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "glogv: alert %s: %v\n", r.name, err)
+			}
+		}()
+	case "webhook":
+		body, _ := json.Marshal(map[string]any{
+			"alert":  r.name,
+			"count":  count,
+			"window": r.window.String(),
+			"line":   string(e.Raw),
+		})
+		go func(url string) {
+			client := &http.Client{Timeout: 10 * time.Second}
+			resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 300 {
+					err = fmt.Errorf("webhook: %s", resp.Status)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "glogv: alert %s: %v\n", r.name, err)
+			}
+		}(r.url)
+	}
+}
+
+// notify shows a desktop notification.
+func notify(title, msg string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", msg, "glogv: "+title))
+	case "windows":
+		cmd = exec.Command("msg", "*", "glogv: "+msg)
+	default:
+		cmd = exec.Command("notify-send", "glogv: "+title, msg)
+	}
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "glogv: alert %s: %v\n", title, err)
+	}
+}
+
+// shellCommand runs a command line with the shell of the platform.
+func shellCommand(s string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", s)
+	}
+	return exec.Command("sh", "-c", s)
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"unicode/utf8"
+)
+
+var alignOn = flag.Bool("align", false, "pad the message and the values of the fields so they line up in columns over the recent entries")
+
+const (
+	alignWindow   = 200 // entries a column keeps the width of its widest value for.
+	maxAlignWidth = 60  // messages and values wider than this are not padded to.
+)
+
+// alignColumn is the width of the message or of the values of a key, the
+// entry it was last widened or narrowed at and the last entry that had it.
+type alignColumn struct {
+	width int
+	at    int
+	seen  int
+}
+
+// messageColumn is the column of the messages, which can not be a key.
+const messageColumn = ""
+
+// the columns by key and the number of entries aligned so far.  alignPeek is
+// set while formatting a line that may never be shown, like the context of
+// -B, so it does not change the columns.
+var (
+	alignColumns = make(map[string]*alignColumn)
+	alignEntries int
+	alignPeek    bool
+)
+
+// alignWidth returns the width of the column of a key with a value n
+// characters wide.  the widest value sets the width until it has not been
+// seen for alignWindow entries, so a stream adjusts to what it is writing.
+func alignWidth(key string, n int) int {
+	c, ok := alignColumns[key]
+	if alignPeek {
+		if !ok {
+			return n
+		}
+		return max(c.width, n)
+	}
+	if !ok {
+		c = &alignColumn{}
+		alignColumns[key] = c
+	}
+	c.seen = alignEntries
+	if n > maxAlignWidth {
+		return n
+	}
+	if n >= c.width || alignEntries-c.at > alignWindow {
+		c.width, c.at = n, alignEntries
+	}
+	return c.width
+}
+
+// alignMissing are the columns added to the keys of the entry being formatted
+// that it does not have.
+var alignMissing = make(map[string]bool)
+
+// addAlignColumns adds the keys of the columns of the recent entries that the
+// keys of an entry do not have, so its values stay in their columns.
+func addAlignColumns(keys []string) []string {
+	clear(alignMissing)
+	for k, c := range alignColumns {
+		if k != messageColumn && alignEntries-c.seen <= alignWindow {
+			alignMissing[k] = true
+		}
+	}
+	for _, k := range keys {
+		delete(alignMissing, k)
+	}
+	for k := range alignMissing {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// appendMissingColumn pads over the column of a key the entry does not have,
+// with its key.
+func appendMissingColumn(b []byte, key string) []byte {
+	return appendPadding(b, 2+utf8.RuneCountInString(key)+alignColumns[key].width)
+}
+
+// appendMessagePadding pads the message of the entry to the width of the
+// message column.
+func appendMessagePadding(b []byte, e *Entry) []byte {
+	n := 0
+	if e.Message != "" {
+		n = 1 + utf8.RuneCountInString(e.Message)
+	}
+	return appendPadding(b, alignWidth(messageColumn, n)-n)
+}
+
+// appendPadding appends n spaces.
+func appendPadding(b []byte, n int) []byte {
+	for ; n > 0; n-- {
+		b = append(b, ' ')
+	}
+	return b
+}
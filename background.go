@@ -0,0 +1,68 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// detectBackground guesses if the terminal has a light or dark background
+// and returns "light" or "dark".  the COLORFGBG environment variable set by
+// some terminals is checked first, then the terminal is asked for its
+// background color.  dark is assumed if neither works.
+func detectBackground() string {
+	if v := os.Getenv("COLORFGBG"); v != "" {
+		fields := strings.Split(v, ";")
+		if bg, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+			// 7 (white) and the bright colors other than 8 (gray) are light.
+			if bg == 7 || bg > 8 {
+				return "light"
+			}
+			return "dark"
+		}
+	}
+
+	if isTerminal(os.Stdout) {
+		if light, ok := queryBackground(); ok && light {
+			return "light"
+		}
+	}
+
+	return "dark"
+}
+
+// returns true if f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// parseBackground parses the reply to an OSC 11 background color query, which
+// looks like "\033]11;rgb:ffff/ffff/ffff\033\\", and returns true if the color
+// is light.
+func parseBackground(reply string) (light bool, ok bool) {
+	i := strings.Index(reply, "rgb:")
+	if i < 0 {
+		return false, false
+	}
+	reply = strings.TrimRight(reply[i+4:], "\033\\\a")
+
+	parts := strings.Split(reply, "/")
+	if len(parts) != 3 {
+		return false, false
+	}
+
+	var rgb [3]float64
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 16, 16)
+		if err != nil || len(p) == 0 {
+			return false, false
+		}
+		// components may have 1 to 4 hex digits.
+		rgb[i] = float64(n) / float64(uint64(1)<<(4*len(p))-1)
+	}
+
+	luma := 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+	return luma > 0.5, true
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// how long to wait for the terminal to answer the background color query.
+const queryTimeout = 200 * time.Millisecond
+
+// queryBackground asks the terminal for its background color with an OSC 11
+// query.  ok is false if the terminal did not answer in time.
+func queryBackground() (light bool, ok bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, false
+	}
+	defer tty.Close()
+
+	// switch the terminal to non-canonical mode without echo so the reply can
+	// be read as soon as it arrives, with reads timing out after 100ms.
+	var old syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TCGETS, &old); err != nil {
+		return false, false
+	}
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1
+	if err := ioctl(tty.Fd(), syscall.TCSETS, &raw); err != nil {
+		return false, false
+	}
+	defer ioctl(tty.Fd(), syscall.TCSETS, &old)
+
+	if _, err := tty.WriteString("\033]11;?\033\\"); err != nil {
+		return false, false
+	}
+
+	var reply []byte
+	buf := make([]byte, 64)
+	deadline := time.Now().Add(queryTimeout)
+	for time.Now().Before(deadline) {
+		n, err := tty.Read(buf)
+		if err != nil {
+			break
+		}
+		reply = append(reply, buf[:n]...)
+		if l := len(reply); l > 0 && (reply[l-1] == '\a' || reply[l-1] == '\\') {
+			return parseBackground(string(reply))
+		}
+	}
+
+	return false, false
+}
+
+func ioctl(fd uintptr, req uint, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
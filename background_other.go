@@ -0,0 +1,10 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build !linux
+
+package main
+
+// queryBackground is not supported on this platform.
+func queryBackground() (light bool, ok bool) {
+	return false, false
+}
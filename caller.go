@@ -0,0 +1,82 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+var (
+	callerKey  = flag.String("caller-key", "caller", "field with the file:line of the log call, shown dim at the end of the line, empty to show it with the other fields")
+	callerLink = flag.String("caller-link", "", "link the -caller-key field to this url when colors are on, %f is replaced by the file and %l by the line, like vscode://file/${PWD}/%f:%l")
+)
+
+// callerURL is -caller-link with the environment expanded, empty if the
+// caller is not linked.
+var callerURL string
+
+// setCallerLink expands -caller-link, links are only written to terminals
+// as they are escape sequences like the colors.
+func setCallerLink() {
+	callerURL = ""
+	if colorsOn {
+		callerURL = os.ExpandEnv(*callerLink)
+	}
+}
+
+// isCaller returns true if k is the -caller-key field.
+func isCaller(k string) bool {
+	return *callerKey != "" && k == *callerKey
+}
+
+// appendCaller appends the caller of the entry, as an OSC 8 hyperlink if
+// -caller-link is set.
+func appendCaller(b []byte, e *Entry) []byte {
+	v, ok := e.Fields[*callerKey]
+	if *callerKey == "" || !ok || !showKey(*callerKey) {
+		return b
+	}
+	s, ok := v.(string)
+	if !ok {
+		s = string(appendValue(nil, v))
+	}
+	b = append(b, ' ')
+	b = append(b, dimColor...)
+	if url := linkCaller(s); url != "" {
+		b = append(b, "\033]8;;"...)
+		b = append(b, url...)
+		b = append(b, "\033\\"...)
+		b = append(b, s...)
+		b = append(b, "\033]8;;\033\\"...)
+	} else {
+		b = append(b, s...)
+	}
+	return append(b, colorReset...)
+}
+
+// linkCaller returns the -caller-link url of a file:line caller, or an empty
+// string if there is none.  a caller with control characters is not linked
+// as they would end the escape sequence.
+func linkCaller(s string) string {
+	if callerURL == "" || strings.ContainsFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return ""
+	}
+	file, line := s, ""
+	if i := strings.LastIndexByte(s, ':'); i >= 0 && isDigits(s[i+1:]) {
+		file, line = s[:i], s[i+1:]
+	}
+	return strings.NewReplacer("%f", file, "%l", line).Replace(callerURL)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
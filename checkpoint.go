@@ -0,0 +1,93 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-json"
+)
+
+// cursor is the position just past the last line displayed from a file.
+type cursor struct {
+	fileID
+	Offset int64 `json:"offset"`
+}
+
+// checkpoint records how far each followed file has been read so that
+// -tail can resume exactly where it left off after a restart.
+type checkpoint struct {
+	path  string
+	files map[string]cursor
+	dirty bool
+}
+
+// loadCheckpoint reads the checkpoint file at path.  a missing file is not
+// an error, it just means nothing has been followed yet.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{path: path, files: make(map[string]cursor)}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &cp.files); err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// get returns the saved position of the file, or nil if there is none.
+func (cp *checkpoint) get(file string) *cursor {
+	cur, ok := cp.files[file]
+	if !ok {
+		return nil
+	}
+	return &cur
+}
+
+// set updates the saved position of the file.
+func (cp *checkpoint) set(file string, cur cursor) {
+	cp.files[file] = cur
+	cp.dirty = true
+}
+
+// save writes the checkpoint file if anything has changed.  the file is
+// written to a temporary file first so it is never left half written.
+func (cp *checkpoint) save() error {
+	if !cp.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(cp.files)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), cp.path); err != nil {
+		return err
+	}
+
+	cp.dirty = false
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// common log format, optionally followed by the referer and user agent of
+// the combined log format.
+var clfLine = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// time layout of the common log format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// returns true if the line looks like an apache or nginx access log.
+func isCLF(b []byte) bool {
+	return clfLine.Match(b)
+}
+
+// parseCLF parses a common or combined log format line.  the request is the
+// message and the level is based on the response status.
+func parseCLF(e *Entry, b []byte) error {
+	m := clfLine.FindSubmatch(b)
+	if m == nil {
+		return errNoMatch
+	}
+
+	clear(e.Fields)
+	status := string(m[6])
+	switch {
+	case status >= "500":
+		e.Fields["level"] = "error"
+	case status >= "400":
+		e.Fields["level"] = "warn"
+	default:
+		e.Fields["level"] = "info"
+	}
+	e.Fields["message"] = string(m[5])
+	e.Fields["status"] = status
+	for i, key := range []string{1: "remote_addr", 2: "ident", 3: "user", 7: "bytes", 8: "referer", 9: "user_agent"} {
+		if key != "" {
+			setTextField(e, key, m[i])
+		}
+	}
+	extract(e, &stdKeys)
+	e.Time, _ = time.Parse(clfTime, localizeTime(string(m[4])))
+
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"container/list"
+	"flag"
+	"sort"
+	"time"
+)
+
+var compareKey = flag.String("compare", "", "under an error with the given field, show what changed since the last entry with the same value that was not an error")
+
+const (
+	maxCompared  = 10000 // ids whose last good entry is remembered by -compare.
+	maxCompDiffs = 8     // changed fields shown under an error.
+)
+
+// goodEntry is what -compare remembers of the last entry of an id that was
+// not an error, with the values of the fields as they are displayed.
+type goodEntry struct {
+	id      string
+	time    time.Time
+	message string
+	fields  map[string]string
+}
+
+// the good entries by id, the least recently seen ids are forgotten first.
+var (
+	goodByID = make(map[string]*list.Element)
+	goodLRU  = list.New()
+)
+
+// compareID returns the -compare id of the entry.
+func compareID(e *Entry) (string, bool) {
+	r := valueRule{key: *compareKey}
+	id, ok := r.value(e)
+	return id, ok && id != ""
+}
+
+// noteGood remembers an entry of an id that is not an error or a warning,
+// whether it is shown or not.
+func noteGood(e *Entry) {
+	if e.Level == "warn" || isError(e.Level) {
+		return
+	}
+	id, ok := compareID(e)
+	if !ok {
+		return
+	}
+
+	var g *goodEntry
+	if el, ok := goodByID[id]; ok {
+		goodLRU.MoveToFront(el)
+		g = el.Value.(*goodEntry)
+		clear(g.fields)
+	} else {
+		if goodLRU.Len() >= maxCompared {
+			old := goodLRU.Remove(goodLRU.Back()).(*goodEntry)
+			delete(goodByID, old.id)
+		}
+		g = &goodEntry{id: id, fields: make(map[string]string)}
+		goodByID[id] = goodLRU.PushFront(g)
+	}
+	g.time, g.message = e.Time, e.Message
+	for k, v := range e.Fields {
+		if k != *compareKey && !isNested(v) && !isStack(k, v) {
+			g.fields[k] = displayValue(v)
+		}
+	}
+}
+
+func isError(level string) bool {
+	return level == "error" || level == "fatal" || level == "panic"
+}
+
+func displayValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return string(appendValue(nil, v))
+}
+
+// appendComparison appends a line under an error entry with the time and
+// message of the last good entry of its id and the fields that changed since.
+func appendComparison(b []byte, e *Entry) []byte {
+	if !isError(e.Level) {
+		return b
+	}
+	id, ok := compareID(e)
+	if !ok {
+		return b
+	}
+	el, ok := goodByID[id]
+	if !ok {
+		return b
+	}
+	g := el.Value.(*goodEntry)
+
+	b = append(b, colorReset...)
+	b = append(b, '\n')
+	b = append(b, expandIndent...)
+	b = append(b, tagColor...)
+	b = append(b, "last ok "...)
+	b = appendDisplayTime(b, g.time)
+	if !g.time.IsZero() && !e.Time.IsZero() {
+		b = append(b, ", "...)
+		b = append(b, e.Time.Sub(g.time).Round(time.Millisecond).String()...)
+		b = append(b, " before"...)
+	}
+	b = append(b, ':')
+	if g.message != "" {
+		b = append(b, ' ')
+		b = append(b, dimColor...)
+		b = append(b, g.message...)
+		b = append(b, colorReset...)
+	}
+
+	// the fields that changed, were added or went away.
+	var changed []string
+	for k, v := range g.fields {
+		if val, ok := e.Fields[k]; (!ok || displayValue(val) != v) && showKey(k) {
+			changed = append(changed, k)
+		}
+	}
+	for k, v := range e.Fields {
+		if _, ok := g.fields[k]; !ok && k != *compareKey && !isNested(v) && !isStack(k, v) && showKey(k) {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	for i, k := range changed {
+		if i == maxCompDiffs {
+			b = append(b, tagColor...)
+			b = append(b, " ..."...)
+			break
+		}
+		before, ok := g.fields[k]
+		if !ok {
+			before = "-"
+		}
+		after := "-"
+		if v, ok := e.Fields[k]; ok {
+			after = displayValue(v)
+		}
+		b = append(b, ' ')
+		b = append(b, tagColor...)
+		b = append(b, k...)
+		b = append(b, ' ')
+		b = append(b, dimColor...)
+		b = append(b, before...)
+		b = append(b, colorReset...)
+		b = append(b, tagColor...)
+		b = append(b, " → "...)
+		b = append(b, color["error"]...)
+		b = append(b, after...)
+	}
+	return b
+}
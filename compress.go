@@ -0,0 +1,144 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressor reads a compressed file, which is recognized by the magic
+// bytes it starts with.  the extensions are used to find rotated files.
+type decompressor struct {
+	name  string
+	exts  []string
+	magic []byte
+	// header, if set, is the length of the start of the file that valid
+	// checks further than the magic bytes.
+	header int
+	valid  func(b []byte) bool
+	open   func(r io.Reader) (io.ReadCloser, error)
+}
+
+// the supported compression formats.
+var decompressors = []decompressor{
+	{
+		name:  "gzip",
+		exts:  []string{".gz"},
+		magic: []byte{0x1f, 0x8b},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:  "zstd",
+		exts:  []string{".zst", ".zstd"},
+		magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	},
+	{
+		name:   "bzip2",
+		exts:   []string{".bz2"},
+		magic:  []byte("BZh"),
+		header: 10,
+		valid:  isBzip2,
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		name:  "xz",
+		exts:  []string{".xz"},
+		magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xr), nil
+		},
+	},
+	{
+		name:  "lz4",
+		exts:  []string{".lz4"},
+		magic: []byte{0x04, 0x22, 0x4d, 0x18},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(lz4.NewReader(r)), nil
+		},
+	},
+}
+
+// findDecompressor returns the decompressor of a file by the magic bytes it
+// starts with, or nil if it is not compressed.  the extension is not used as
+// renamed files, stdin and pipes like /dev/fd/63 from process substitution
+// have no useful name, and a .gz file that was already decompressed is read
+// as is.
+func findDecompressor(br *bufio.Reader) *decompressor {
+	for i := range decompressors {
+		d := &decompressors[i]
+		magic, _ := br.Peek(len(d.magic))
+		if !bytes.Equal(magic, d.magic) {
+			continue
+		}
+		if d.valid != nil {
+			if b, _ := br.Peek(d.header); !d.valid(b) {
+				continue
+			}
+		}
+		return d
+	}
+	return nil
+}
+
+// isBzip2 returns true if the start of a file is the block size of bzip2,
+// '1' to '9', and the magic of its first block or of the end of an empty
+// stream, so a text line starting with BZh is not taken for one.
+func isBzip2(b []byte) bool {
+	if len(b) < 10 || b[3] < '1' || b[3] > '9' {
+		return false
+	}
+	block := string(b[4:10])
+	return block == "\x31\x41\x59\x26\x53\x59" || block == "\x17\x72\x45\x38\x50\x90"
+}
+
+// decompress returns a reader of the decompressed file and the decompressor
+// to close, or br and nil if the file is not compressed.
+func decompress(file string, br *bufio.Reader) (*bufio.Reader, io.ReadCloser, error) {
+	d := findDecompressor(br)
+	if d == nil {
+		return br, nil, nil
+	}
+	dec, err := d.open(br)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return bufio.NewReader(dec), dec, nil
+}
+
+// isCompressedName returns true if the extension of the file is one of a
+// compression format.
+func isCompressedName(file string) bool {
+	ext := filepath.Ext(file)
+	for _, d := range decompressors {
+		for _, e := range d.exts {
+			if ext == e {
+				return true
+			}
+		}
+	}
+	return false
+}
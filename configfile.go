@@ -0,0 +1,324 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// colorNames are the names that can be used for a color in the config file
+// and -color-level.  a #rrggbb color is shown in truecolor and anything else
+// is used as the parameters of an SGR escape sequence, like 38;5;208 for one
+// of the 256 colors.
+var colorNames = map[string]string{
+	"gray":         colorGray,
+	"green":        colorGreen,
+	"red":          colorRed,
+	"yellow":       colorYellow,
+	"blue":         colorBlue,
+	"purple":       colorPurple,
+	"cyan":         colorCyan,
+	"white":        colorWhite,
+	"black":        "\033[30m",
+	"brightred":    "\033[91m",
+	"brightgreen":  "\033[92m",
+	"brightyellow": "\033[93m",
+	"brightblue":   "\033[94m",
+	"brightpurple": "\033[95m",
+	"brightcyan":   "\033[96m",
+	"brightwhite":  "\033[97m",
+}
+
+// noConfigFlags are the flags that are not read from or written to the
+// config file, like the aliases of other flags.
+var noConfigFlags = map[string]bool{
+	"t":           true,
+	"v":           true,
+	"F":           true,
+	"config":      true,
+	"profile":     true,
+	"dump-config": true,
+	"cpuprofile":  true,
+	"memprofile":  true,
+}
+
+// configColors holds the [colors] table of the config file, applied on top of
+// the theme.
+var configColors map[string]string
+
+// colorLevels are the level=color overrides of -color-level, applied on top
+// of the config file colors.
+var colorLevels listFlag
+
+func init() {
+	flag.Var(&colorLevels, "color-level", "override the color of a level, time or tag, like error=brightred or warn=#b58900, may be repeated")
+}
+
+// configPath returns the config file to load and whether it must exist.
+func configPath() (string, bool) {
+	if *configFile != "" {
+		return *configFile, true
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "glogv", "config.toml"), false
+}
+
+// the flags given on the command line, which take precedence over the config
+// file.  setting a flag from the file marks it as set, so they are only
+// collected the first time the file is loaded.
+var cmdlineFlags map[string]bool
+
+// the short flags that set the same option as a longer one.
+var flagAliases = map[string]string{
+	"t": "tail",
+	"v": "invert",
+}
+
+// loadConfigFile sets every flag named in the config file that was not given
+// on the command line.
+func loadConfigFile() error {
+	if cmdlineFlags == nil {
+		cmdlineFlags = make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) {
+			cmdlineFlags[f.Name] = true
+			if name, ok := flagAliases[f.Name]; ok {
+				cmdlineFlags[name] = true
+			}
+		})
+	}
+
+	path, required := configPath()
+	if path == "" {
+		return nil
+	}
+
+	var values map[string]any
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	if err := setFlags(values, cmdlineFlags); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	// the selected profile is applied on top of the rest of the file.
+	if *profileName != "" {
+		profiles, _ := values["profile"].(map[string]any)
+		profile, ok := profiles[*profileName].(map[string]any)
+		if !ok {
+			return fmt.Errorf("config %s: no profile %q", path, *profileName)
+		}
+		if err := setFlags(profile, cmdlineFlags); err != nil {
+			return fmt.Errorf("config %s: profile %s: %w", path, *profileName, err)
+		}
+	}
+
+	return nil
+}
+
+// setFlags sets the flags named by the keys of a config table unless they
+// were given on the command line.
+func setFlags(values map[string]any, cmdline map[string]bool) error {
+	for key, val := range values {
+		switch key {
+		case "colors":
+			if err := loadColors(val); err != nil {
+				return err
+			}
+			continue
+		case "profile":
+			continue
+		}
+		if flag.Lookup(key) == nil || noConfigFlags[key] {
+			return fmt.Errorf("unknown key %q", key)
+		}
+		if cmdline[key] || (reloading && followFlags[key]) {
+			continue
+		}
+		// the items of a flag that may be repeated are set one at a time.
+		if list, ok := val.([]any); ok && listValue(flag.Lookup(key)) != nil {
+			for _, v := range list {
+				if err := flag.Set(key, fmt.Sprint(v)); err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+			continue
+		}
+		if err := flag.Set(key, configValue(val)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// configValue converts a config file value to its flag form, arrays become
+// comma separated lists.
+func configValue(val any) string {
+	if list, ok := val.([]any); ok {
+		items := make([]string, len(list))
+		for i, v := range list {
+			items[i] = fmt.Sprint(v)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(val)
+}
+
+func loadColors(val any) error {
+	table, ok := val.(map[string]any)
+	if !ok {
+		return errors.New("colors must be a table")
+	}
+
+	configColors = make(map[string]string, len(table))
+	for key, v := range table {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("color %q must be a string", key)
+		}
+		clr, err := parseColor(key, s)
+		if err != nil {
+			return err
+		}
+		configColors[key] = clr
+	}
+
+	return nil
+}
+
+// parseColor returns the escape sequence of a color of the level, time or
+// tag.
+func parseColor(key, s string) (string, error) {
+	if _, ok := color[key]; !ok && key != "time" && key != "tag" {
+		return "", fmt.Errorf("unknown color %q", key)
+	}
+	return colorCode(key, s)
+}
+
+// colorCode returns the escape sequence of a color name, a #rrggbb color or
+// the parameters of an SGR escape sequence.  key names what the color is for
+// in errors.
+func colorCode(key, s string) (string, error) {
+	if clr, ok := colorNames[s]; ok {
+		return clr, nil
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return "", fmt.Errorf("color %q: %q is not #rrggbb", key, s)
+		}
+		return fmt.Sprintf("\033[38;2;%d;%d;%dm", rgb>>16, rgb>>8&0xff, rgb&0xff), nil
+	}
+	if s == "" || strings.Trim(s, "0123456789;:") != "" {
+		return "", fmt.Errorf("color %q: unknown color %q", key, s)
+	}
+	return "\033[" + s + "m", nil
+}
+
+// colorOverrides returns the colors of the config file with those of
+// -color-level on top.
+func colorOverrides() (map[string]string, error) {
+	colors := maps.Clone(configColors)
+	if colors == nil {
+		colors = make(map[string]string)
+	}
+	for _, spec := range colorLevels {
+		key, s, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("-color-level: %q is not level=color", spec)
+		}
+		clr, err := parseColor(key, s)
+		if err != nil {
+			return nil, fmt.Errorf("-color-level: %w", err)
+		}
+		colors[key] = clr
+	}
+	return colors, nil
+}
+
+// applyColors overrides the colors of the theme.
+func applyColors(colors map[string]string) {
+	for key, clr := range colors {
+		switch key {
+		case "time":
+			timeColor = clr
+		case "tag":
+			tagColor = clr
+		case "info":
+			infoColor = clr
+			fallthrough
+		default:
+			color[key] = clr
+			labelColor[key] = clr
+		}
+	}
+}
+
+// dumpConfig writes the effective configuration in the config file format,
+// with the colors of the [colors] table.
+func dumpConfig(colors map[string]string) error {
+	values := make(map[string]any)
+	flag.VisitAll(func(f *flag.Flag) {
+		if noConfigFlags[f.Name] {
+			return
+		}
+		if l := listValue(f); l != nil {
+			values[f.Name] = append([]string{}, *l...)
+			return
+		}
+		if g, ok := f.Value.(flag.Getter); ok {
+			switch v := g.Get().(type) {
+			case bool, int, string:
+				values[f.Name] = v
+				return
+			}
+		}
+		values[f.Name] = f.Value.String()
+	})
+
+	values["colors"] = colors
+	return toml.NewEncoder(os.Stdout).Encode(values)
+}
+
+// colorTable returns the current colors as the [colors] table of the config
+// file.
+func colorTable() map[string]string {
+	colors := make(map[string]string)
+	for key, clr := range color {
+		colors[key] = sgrParams(clr)
+	}
+	colors["time"] = sgrParams(timeColor)
+	colors["tag"] = sgrParams(tagColor)
+	return colors
+}
+
+// sgrParams returns the name or parameters of a color escape sequence.
+func sgrParams(clr string) string {
+	for name, c := range colorNames {
+		if c == clr {
+			return name
+		}
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(clr, "\033["), "m")
+}
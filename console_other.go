@@ -0,0 +1,12 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableColors does nothing, terminals show escape sequences as they are.
+func enableColors(f *os.File) bool {
+	return true
+}
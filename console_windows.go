@@ -0,0 +1,31 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminalProcessing makes the console interpret escape
+// sequences instead of printing them.
+const enableVirtualTerminalProcessing = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableColors turns on the escape sequences of the console of f, which older
+// consoles do not support.  it returns false if they can not be shown.
+func enableColors(f *os.File) bool {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	ok, _, _ := setConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	return ok != 0
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+)
+
+var (
+	afterLines  = flag.Int("A", 0, "show n lines after each -grep match, dimmed")
+	beforeLines = flag.Int("B", 0, "show n lines before each -grep match, dimmed")
+	aroundLines = flag.Int("C", 0, "show n lines before and after each -grep match, dimmed")
+)
+
+// contextLine is a formatted line kept in case a match follows it.
+type contextLine struct {
+	src  string
+	text []byte
+}
+
+// the lines kept for -B, how many lines of -A are left to show, and whether
+// lines were left out since the last line shown, which is marked with -- like
+// grep does.
+var (
+	ctxBefore []contextLine
+	ctxAfter  int
+	ctxGap    bool
+	ctxShown  bool
+)
+
+func contextBefore() int { return max(*beforeLines, *aroundLines) }
+func contextAfter() int  { return max(*afterLines, *aroundLines) }
+
+// contextWanted returns true if lines that do not match -grep are shown
+// around those that do.
+func contextWanted() bool {
+	return contextBefore() > 0 || contextAfter() > 0
+}
+
+// addContext shows a formatted line that did not match, dimmed, if it comes
+// after a match or keeps it in case one follows.
+func addContext(b []byte, src string) {
+	b = dimLine(b)
+	if ctxAfter > 0 {
+		ctxAfter--
+		writeLine(b, src, nil)
+		ctxShown = true
+		return
+	}
+
+	n := contextBefore()
+	if len(ctxBefore) == n {
+		ctxGap = true
+		if n == 0 {
+			return
+		}
+		first := ctxBefore[0]
+		copy(ctxBefore, ctxBefore[1:])
+		ctxBefore = ctxBefore[:n-1]
+		first.src, first.text = src, append(first.text[:0], b...)
+		ctxBefore = append(ctxBefore, first)
+		return
+	}
+	ctxBefore = append(ctxBefore, contextLine{src: src, text: bytes.Clone(b)})
+}
+
+// printContext shows the lines kept before a match.
+func printContext() {
+	if !contextWanted() {
+		return
+	}
+	if ctxGap && ctxShown {
+		writeLine([]byte(tagColor+"--"+colorReset+"\n"), "", nil)
+	}
+	for _, l := range ctxBefore {
+		writeLine(l.text, l.src, nil)
+	}
+	ctxBefore = ctxBefore[:0]
+	ctxGap, ctxShown = false, true
+}
+
+// startContext starts showing the lines after a match.
+func startContext() {
+	ctxAfter = contextAfter()
+}
+
+// dimLine returns the formatted line without its colors in the dim color.
+func dimLine(b []byte) []byte {
+	text := bytes.TrimSuffix(stripColors(b), []byte("\n"))
+	dimmed := make([]byte, 0, len(text)+16)
+	dimmed = append(dimmed, dimColor...)
+	dimmed = append(dimmed, text...)
+	dimmed = append(dimmed, colorReset...)
+	return append(dimmed, '\n')
+}
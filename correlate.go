@@ -0,0 +1,56 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"strconv"
+)
+
+// maxCorrelated is the number of ids remembered by -correlate, the oldest are
+// forgotten all at once when there are more so a long follow does not grow
+// without bound.
+const maxCorrelated = 10000
+
+// the colors the source labels and the markers of -correlate cycle through,
+// picked to stand apart from the level colors.
+var markColors = []string{
+	"\033[94m",
+	"\033[95m",
+	"\033[96m",
+	"\033[93m",
+	"\033[92m",
+	"\033[91m",
+	"\033[38;5;208m",
+	"\033[38;5;141m",
+}
+
+// the marker number of each id seen by -correlate and the last one handed out.
+var (
+	correlated = make(map[string]int)
+	lastMarker int
+)
+
+// appendCorrelation appends the marker of the -correlate id of the entry, the
+// same id gets the same number and color in every source.
+func appendCorrelation(b []byte, e *Entry) []byte {
+	r := valueRule{key: *correlateKey}
+	id, ok := r.value(e)
+	if !ok || id == "" {
+		return b
+	}
+
+	n, ok := correlated[id]
+	if !ok {
+		if len(correlated) >= maxCorrelated {
+			clear(correlated)
+		}
+		lastMarker++
+		n = lastMarker
+		correlated[id] = n
+	}
+
+	b = append(b, ' ')
+	b = append(b, markColors[(n-1)%len(markColors)]...)
+	b = append(b, '#')
+	b = strconv.AppendInt(b, int64(n), 10)
+	return append(b, colorReset...)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// criPrefix matches the start of the lines a container runtime writes to the
+// container log files of a kubernetes node, the time, the stream and whether
+// the line is complete (F) or continued on the next one (P).
+var criPrefix = regexp.MustCompile(`^(\d{4}-\d\d-\d\dT[\d:.]+(?:Z|[+-]\d\d:\d\d)) (stdout|stderr) ([FP]) ?`)
+
+var (
+	criPartial = make(map[string][]byte) // the continued lines of each source.
+	criTime    time.Time                 // the time of the line being displayed, if it is a cri line.
+)
+
+// unwrapCRI returns the log line of a cri container log line, which is wrapped
+// in a prefix added by the runtime.  false is returned for a partial line,
+// which is joined with the lines that follow it.  other lines are returned as
+// they are.
+func unwrapCRI(src string, b []byte) ([]byte, bool) {
+	criTime = time.Time{}
+	if len(b) < 30 || b[4] != '-' || b[10] != 'T' {
+		return b, true
+	}
+	m := criPrefix.FindSubmatchIndex(b)
+	if m == nil {
+		return b, true
+	}
+
+	msg := b[m[1]:]
+	if b[m[6]] == 'P' {
+		criPartial[src] = append(criPartial[src], msg...)
+		return nil, false
+	}
+	if partial, ok := criPartial[src]; ok {
+		msg = append(partial, msg...)
+		delete(criPartial, src)
+	}
+	criTime, _ = time.Parse(time.RFC3339Nano, string(b[m[2]:m[3]]))
+	return msg, true
+}
+
+// flushCRI displays the partial line of a source that ended without the
+// rest of it.
+func flushCRI(src string) {
+	if partial, ok := criPartial[src]; ok {
+		delete(criPartial, src)
+		criTime = time.Time{}
+		setRawLine(src, nil)
+		reformatPayload(src, partial)
+	}
+}
+
+// criLineTime returns the time of a cri container log line, or the zero time
+// for other lines.
+func criLineTime(b []byte) time.Time {
+	if len(b) < 30 || b[4] != '-' || b[10] != 'T' {
+		return time.Time{}
+	}
+	m := criPrefix.FindSubmatchIndex(b)
+	if m == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, string(b[m[2]:m[3]]))
+	return t
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strconv"
+	"strings"
+)
+
+var (
+	dedup     = flag.Bool("dedup", false, "collapse consecutive entries with the same level and message into one line with the number of repeats")
+	dedupKeys listFlag
+)
+
+func init() {
+	flag.Var(&dedupKeys, "dedup-key", "with -dedup, entries also need the same value of this field to be collapsed, may be repeated")
+}
+
+// dedupRun is the line of the entries that are being collapsed, which is
+// written when an entry that is not the same is shown.
+type dedupRun struct {
+	held  bool
+	key   string
+	text  []byte
+	src   string
+	entry Entry // the level, time and raw line of the first entry, for the -tui.
+	count int
+	seen  int // the count at the last tick, to tell if the run is still going.
+}
+
+var repeats dedupRun
+
+// dedupKey returns what entries need to have in common to be collapsed.
+func dedupKey(e *Entry) string {
+	var sb strings.Builder
+	sb.WriteString(e.Source)
+	sb.WriteByte(0)
+	sb.WriteString(e.Level)
+	sb.WriteByte(0)
+	sb.WriteString(e.Message)
+	for _, k := range dedupKeys {
+		r := valueRule{key: k}
+		val, _ := r.value(e)
+		sb.WriteByte(0)
+		sb.WriteString(val)
+	}
+	return sb.String()
+}
+
+// holdLine holds the line of an entry until it is known how many times it is
+// repeated, the line held before is written if it is not the same.
+func holdLine(b []byte, e *Entry) {
+	key := dedupKey(e)
+	if repeats.held && key == repeats.key {
+		repeats.count++
+		return
+	}
+	flushDedup()
+	repeats.held, repeats.key, repeats.count, repeats.seen = true, key, 1, 0
+	repeats.text = append(repeats.text[:0], b...)
+	repeats.src = e.Source
+	repeats.entry = Entry{Time: e.Time, Level: e.Level, Raw: bytes.Clone(e.Raw), Source: e.Source}
+}
+
+// flushDedup writes the held line with the number of times it was repeated.
+// it is called before anything else is written so the output stays in order.
+func flushDedup() {
+	if !repeats.held {
+		return
+	}
+	repeats.held = false
+	b := repeats.text
+	if repeats.count > 1 {
+		// the count goes at the end of the first line, before the -expand lines.
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			i = len(b)
+		}
+		note := " " + tagColor + "(×" + strconv.Itoa(repeats.count) + ")" + colorReset
+		b = append(b[:i:i], append([]byte(note), b[i:]...)...)
+	}
+	writeLine(b, repeats.src, &repeats.entry)
+}
+
+// dedupTick writes the held line once no repeat was seen since the last tick,
+// so a run is not held back forever while following.
+func dedupTick() {
+	if repeats.held && repeats.count == repeats.seen {
+		flushDedup()
+		return
+	}
+	repeats.seen = repeats.count
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import "sync"
+
+// dropper counts the lines of a source that are dropped with -drop because
+// the channel to the output is full.  the lock is held while sending so the
+// count can be reported by the output without getting ahead of the lines
+// that were sent before the drops.
+type dropper struct {
+	sync.Mutex
+	src string
+	n   int
+	cur *cursor
+}
+
+// send sends a line without waiting, the count of lines dropped before it
+// has to be sent first.
+func (d *dropper) send(lines chan<- sourcedLine, l logLine) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.n > 0 {
+		select {
+		case lines <- d.line():
+			d.n, d.cur = 0, nil
+		default:
+		}
+	}
+	if d.n == 0 {
+		src := d.src
+		if l.file != "" {
+			src = l.file
+		}
+		select {
+		case lines <- sourcedLine{src: src, logLine: l}:
+			return
+		default:
+		}
+	}
+	d.n++
+	if l.file == "" {
+		// the position of one of the files of a glob is not that of the glob.
+		d.cur = l.cur
+	}
+}
+
+// finish sends the count of dropped lines when the source ends.
+func (d *dropper) finish(lines chan<- sourcedLine) {
+	d.Lock()
+	l := d.line()
+	d.n, d.cur = 0, nil
+	d.Unlock()
+
+	if l.dropped > 0 {
+		lines <- l
+	}
+}
+
+// report shows the count of dropped lines from the output side, after the
+// lines that are still waiting in the channel.
+func (d *dropper) report(lines chan sourcedLine, show func(string, logLine)) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.n == 0 {
+		return
+	}
+	for n := len(lines); n > 0; n-- {
+		l := <-lines
+		show(l.src, l.logLine)
+	}
+	l := d.line()
+	show(l.src, l.logLine)
+	d.n, d.cur = 0, nil
+}
+
+func (d *dropper) line() sourcedLine {
+	return sourcedLine{src: d.src, logLine: logLine{dropped: d.n, cur: d.cur}}
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// Entry is a parsed log line.  it is produced once per line by the parser
+// and used by everything that looks at the line after that.
+type Entry = format.Entry
+
+// the current log entry, it is reused for every line to avoid allocating a
+// new map each time.
+var entry = Entry{Fields: make(map[string]any)}
+
+// the number of lines read from each source.
+var lineNos = make(map[string]int)
+
+// the sources whose line numbers are not known, as -last seeked past the
+// start of the file.
+var unnumbered = make(map[string]bool)
+
+// nextLineNo counts a line read from a source and returns its number, or 0
+// if it is not known.
+func nextLineNo(src string) int {
+	lineNos[src]++
+	if unnumbered[src] {
+		return 0
+	}
+	return lineNos[src]
+}
+
+// parseJSON unmarshals a json log line into e.Fields and then moves the
+// standard logging fields out of it.
+func parseJSON(e *Entry, b []byte, cfg *sourceConfig) error {
+	p := parser(&cfg.json)
+	return p.ParseJSON(e, b)
+}
+
+// extract moves the standard logging fields out of e.Fields, using the
+// given keys to find them.
+func extract(e *Entry, keys *fieldKeys) {
+	p := parser(keys)
+	p.Extract(e)
+}
+
+// parser returns the parser of the keys with the options of the command line.
+func parser(keys *fieldKeys) format.Parser {
+	return format.Parser{
+		Keys:          *keys,
+		KeepNested:    *expand,
+		NumericLevels: numericLevels,
+		TimeLayouts:   timeLayouts,
+		TimeZone:      timeZone,
+		TimeNames:     timeNames,
+	}
+}
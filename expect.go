@@ -0,0 +1,112 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// expectFlag is a flag that can be given more than once, unlike listFlag its
+// values are not split on commas.
+type expectFlag []string
+
+func (l *expectFlag) String() string { return strings.Join(*l, " ") }
+
+func (l *expectFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var expectSpecs expectFlag
+
+func init() {
+	flag.Var(&expectSpecs, "expect", "mark entries whose field has a value outside of key=a,b,c and report them with -stats, may be repeated")
+}
+
+// expectRule is the set of values a field is expected to have, along with
+// how many times each unexpected value was seen.
+type expectRule struct {
+	key        string
+	allowed    map[string]bool
+	unexpected map[string]int
+}
+
+var expectRules []*expectRule
+
+// setExpects parses the -expect sets, the values of a key given more than
+// once are added to the same set.
+func setExpects() error {
+	expectRules = nil
+	for _, s := range expectSpecs {
+		key, vals, ok := strings.Cut(s, "=")
+		if !ok || key == "" || vals == "" {
+			return fmt.Errorf("-expect: %q is not key=value,value", s)
+		}
+		var r *expectRule
+		for _, old := range expectRules {
+			if old.key == key {
+				r = old
+			}
+		}
+		if r == nil {
+			r = &expectRule{key: key, allowed: make(map[string]bool), unexpected: make(map[string]int)}
+			expectRules = append(expectRules, r)
+		}
+		for _, v := range strings.Split(vals, ",") {
+			r.allowed[v] = true
+		}
+	}
+	return nil
+}
+
+// check returns the value of the field if the entry has it and it is not one
+// of the expected ones.  a level that is not known is checked as it was
+// written instead of as the level it defaults to.
+func (r *expectRule) check(e *Entry) (string, bool) {
+	field := valueRule{key: r.key}
+	val, ok := field.value(e)
+	if r.key == "level" && e.RawLevel != "" {
+		val = e.RawLevel
+	}
+	if !ok || r.allowed[val] {
+		return "", false
+	}
+	return val, true
+}
+
+// countExpects counts the unexpected values of the entry.
+func countExpects(e *Entry) {
+	for _, r := range expectRules {
+		if val, ok := r.check(e); ok {
+			countValue(r.unexpected, val)
+		}
+	}
+}
+
+// appendExpects marks the fields of the entry with unexpected values.
+func appendExpects(b []byte, e *Entry) []byte {
+	for _, r := range expectRules {
+		if val, ok := r.check(e); ok {
+			b = append(b, ' ')
+			b = append(b, highlightColor...)
+			b = append(b, "[unexpected "...)
+			b = append(b, r.key...)
+			b = append(b, '=')
+			b = append(b, val...)
+			b = append(b, ']')
+			b = append(b, colorReset...)
+		}
+	}
+	return b
+}
+
+// printExpects prints the most common unexpected values of each field.
+func printExpects(w io.Writer) {
+	for _, r := range expectRules {
+		if len(r.unexpected) > 0 {
+			printTop(w, "unexpected "+r.key, r.unexpected)
+		}
+	}
+}
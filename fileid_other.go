@@ -0,0 +1,24 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// fileID identifies a file independently of its name.
+type fileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+// getFileID is not supported on this platform, so every file is treated as
+// the same file and rotation is not detected.
+func getFileID(_ string, _ os.FileInfo) fileID {
+	return fileID{}
+}
+
+// openFollowed opens a file to be followed.
+func openFollowed(path string) (*os.File, error) {
+	return os.Open(path)
+}
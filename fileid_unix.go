@@ -0,0 +1,30 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file independently of its name.
+type fileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+// getFileID returns the device and inode of the file.
+func getFileID(_ string, fi os.FileInfo) fileID {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}
+	}
+	return fileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}
+}
+
+// openFollowed opens a file to be followed.
+func openFollowed(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,54 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file independently of its name.
+type fileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+// shareAll lets the writer rename or delete a file while it is being followed.
+const shareAll = syscall.FILE_SHARE_READ | syscall.FILE_SHARE_WRITE | syscall.FILE_SHARE_DELETE
+
+// getFileID returns the volume serial number and file index of the file.
+func getFileID(path string, _ os.FileInfo) fileID {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return fileID{}
+	}
+	h, err := syscall.CreateFile(name, 0, shareAll, nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return fileID{}
+	}
+	defer syscall.CloseHandle(h)
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return fileID{}
+	}
+	return fileID{
+		Dev: uint64(info.VolumeSerialNumber),
+		Ino: uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow),
+	}
+}
+
+// openFollowed opens a file so that it can still be rotated by the writer.
+func openFollowed(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ, shareAll, nil, syscall.OPEN_EXISTING, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+var fileTableOn = flag.Bool("file-table", false, "after the files were read, print the entries, errors, parse errors and time range of each one")
+
+// fileCounts are the counts of a file shown by -file-table.
+type fileCounts struct {
+	entries     int
+	errors      int // error, fatal and panic entries.
+	parseErrors int
+	first, last time.Time
+}
+
+// the counts of each file by label and the labels in the order given, nil
+// without -file-table.
+var (
+	fileTable  map[string]*fileCounts
+	fileLabels []string
+)
+
+// startFileTable starts counting the entries of the files.
+func startFileTable(labels []string) {
+	fileLabels = labels
+	fileTable = make(map[string]*fileCounts, len(labels))
+	for _, l := range labels {
+		fileTable[l] = &fileCounts{}
+	}
+}
+
+// countFileEntry counts an entry read from a file, whether it is shown or
+// not.
+func countFileEntry(e *Entry) {
+	c, ok := fileTable[e.Source]
+	if !ok {
+		return
+	}
+	c.entries++
+	if e.Level == "error" || e.Level == "fatal" || e.Level == "panic" {
+		c.errors++
+	}
+	if e.Time.IsZero() {
+		return
+	}
+	if c.first.IsZero() || e.Time.Before(c.first) {
+		c.first = e.Time
+	}
+	if e.Time.After(c.last) {
+		c.last = e.Time
+	}
+}
+
+// countFileParseError counts a line of a file that could not be parsed.
+func countFileParseError(src string) {
+	if c, ok := fileTable[src]; ok {
+		c.parseErrors++
+	}
+}
+
+// printFileTable prints a line for each file.
+func printFileTable(w io.Writer) {
+	width := len("file")
+	for _, l := range fileLabels {
+		width = max(width, len(l))
+	}
+	fmt.Fprintf(w, "\n%s%-*s %8s %8s %8s  %s%s\n", tagColor, width, "file", "entries", "errors", "unparsed", "time range", colorReset)
+	for _, l := range fileLabels {
+		c := fileTable[l]
+		errs := fmt.Sprintf("%8d", c.errors)
+		if c.errors > 0 {
+			errs = color["error"] + errs + colorReset
+		}
+		span := "-"
+		if !c.first.IsZero() {
+			span = string(appendDisplayTime(nil, c.first)) + " - " + string(appendDisplayTime(nil, c.last))
+		}
+		fmt.Fprintf(w, "%-*s %8d %s %8d  %s%s%s\n", width, l, c.entries, errs, c.parseErrors, timeColor, span, colorReset)
+	}
+}
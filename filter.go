@@ -0,0 +1,239 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// listFlag is a flag that can be given more than once, each value may also
+// be a comma separated list.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, strings.Split(s, ",")...)
+	return nil
+}
+
+var (
+	includeIf listFlag
+	excludeIf listFlag
+)
+
+var (
+	grepExpr = flag.String("grep", "", "only show entries whose message matches the regular expression")
+	grepKey  = flag.String("grep-key", "", "only show entries with a field matching key=regexp")
+	invert   = flag.Bool("invert", false, "hide the entries matched by -grep and -grep-key instead")
+)
+
+func init() {
+	flag.BoolVar(invert, "v", false, "")
+	flag.Var(&includeIf, "include-if", "only show entries with a field matching key=glob, may be repeated")
+	flag.Var(&excludeIf, "exclude-if", "hide entries with a field matching key=glob, may be repeated")
+}
+
+// valueRule matches entries where the value of a field matches a glob.
+type valueRule struct {
+	key     string
+	pattern string
+}
+
+// the parsed -include-if rules grouped by key, and the -exclude-if rules.
+var (
+	includeRules map[string][]valueRule
+	excludeRules []valueRule
+)
+
+// the compiled -grep and -grep-key expressions and the key of -grep-key.
+var (
+	grepRe    *regexp.Regexp
+	grepKeyRe *regexp.Regexp
+	grepField string
+)
+
+// highlightColor marks the text matched by -grep and -grep-key.
+var highlightColor = "\033[7m"
+
+// setValueFilters parses the -include-if, -exclude-if, -grep, -grep-key and
+// -where rules, and the -highlight rules that go with them.
+func setValueFilters() error {
+	grepRe, grepKeyRe, grepField = nil, nil, ""
+	includeRules, excludeRules = nil, nil
+
+	var err error
+	if *grepExpr != "" {
+		if grepRe, err = regexp.Compile(*grepExpr); err != nil {
+			return fmt.Errorf("-grep: %w", err)
+		}
+	}
+	if *grepKey != "" {
+		key, expr, ok := strings.Cut(*grepKey, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("-grep-key: %q is not key=regexp", *grepKey)
+		}
+		if grepKeyRe, err = regexp.Compile(expr); err != nil {
+			return fmt.Errorf("-grep-key: %w", err)
+		}
+		grepField = key
+	}
+
+	for _, s := range includeIf {
+		r, err := parseRule("include-if", s)
+		if err != nil {
+			return err
+		}
+		if includeRules == nil {
+			includeRules = make(map[string][]valueRule)
+		}
+		includeRules[r.key] = append(includeRules[r.key], r)
+	}
+	for _, s := range excludeIf {
+		r, err := parseRule("exclude-if", s)
+		if err != nil {
+			return err
+		}
+		excludeRules = append(excludeRules, r)
+	}
+	if err := setWhere(); err != nil {
+		return err
+	}
+	return setHighlights()
+}
+
+func parseRule(name, s string) (valueRule, error) {
+	key, pattern, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return valueRule{}, fmt.Errorf("-%s: %q is not key=value", name, s)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return valueRule{}, fmt.Errorf("-%s: bad pattern %q", name, pattern)
+	}
+	return valueRule{key: key, pattern: pattern}, nil
+}
+
+// keepEntry returns false if the entry is filtered out by its fields or by
+// -grep and -grep-key.
+func keepEntry(e *Entry) bool {
+	return keepFields(e) && matchGrep(e)
+}
+
+// keepFields returns false if the entry is filtered out by its fields.  an
+// entry outside of -since and -until, not matching -where or matching any
+// -exclude-if rule is always hidden.  otherwise it has to match one of the
+// -include-if rules of every key that has any.
+func keepFields(e *Entry) bool {
+	if !inTimeRange(e.Time) {
+		return false
+	}
+	if whereRule != nil && !whereRule.eval(e) {
+		return false
+	}
+	for _, r := range excludeRules {
+		if r.match(e) {
+			return false
+		}
+	}
+	for _, rules := range includeRules {
+		matched := false
+		for _, r := range rules {
+			if r.match(e) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchGrep returns false if the entry is filtered out by -grep and
+// -grep-key.
+func matchGrep(e *Entry) bool {
+	if grepRe == nil && grepKeyRe == nil {
+		return true
+	}
+	return grepEntry(e) != *invert
+}
+
+// grepEntry returns true if the message matches -grep and the field of
+// -grep-key matches its expression, whichever are set.
+func grepEntry(e *Entry) bool {
+	if grepRe != nil && !grepRe.MatchString(e.Message) {
+		return false
+	}
+	if grepKeyRe != nil {
+		r := valueRule{key: grepField}
+		val, ok := r.value(e)
+		if !ok || !grepKeyRe.MatchString(val) {
+			return false
+		}
+	}
+	return true
+}
+
+// keepPlain returns false if a line that is not a log entry is filtered out
+// by -grep.
+func keepPlain(b []byte) bool {
+	if grepRe == nil {
+		return true
+	}
+	return grepRe.Match(b) != *invert
+}
+
+// appendMatches appends s in clr with the matches of re highlighted.
+func appendMatches(b []byte, s string, re *regexp.Regexp, clr string) []byte {
+	if re == nil || *invert {
+		return append(b, s...)
+	}
+	prev := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		b = append(b, s[prev:m[0]]...)
+		b = append(b, highlightColor...)
+		b = append(b, s[m[0]:m[1]]...)
+		b = append(b, colorReset...)
+		b = append(b, clr...)
+		prev = m[1]
+	}
+	return append(b, s[prev:]...)
+}
+
+// match returns true if the field of the rule is set and matches.
+func (r *valueRule) match(e *Entry) bool {
+	val, ok := r.value(e)
+	if !ok {
+		return false
+	}
+	ok, _ = path.Match(r.pattern, val)
+	return ok
+}
+
+// value returns the field of the rule as it is displayed and false if it is
+// not set.  level, message and error refer to the standard fields.
+func (r *valueRule) value(e *Entry) (string, bool) {
+	switch r.key {
+	case "level":
+		return e.Level, true
+	case "message":
+		return e.Message, true
+	case "error":
+		return e.Error, e.Error != ""
+	}
+	v, ok := e.Fields[r.key]
+	if !ok {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	return string(appendValue(nil, v)), true
+}
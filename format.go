@@ -0,0 +1,133 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// number of lines at the start of each source used to detect its format.
+const detectLines = 10
+
+// errNoMatch is returned by a parser when the line is not in its format.
+var errNoMatch = errors.New("line does not match the format")
+
+// inputFormat parses lines of one log format.
+type inputFormat struct {
+	// detect returns true if the line looks like it is in this format.
+	detect func(b []byte) bool
+	// parse fills the entry from the line, using the options of its source.
+	parse func(e *Entry, b []byte, cfg *sourceConfig) error
+}
+
+// supported input formats, in the order they are tried when detecting.  the
+// looser formats come last so they do not claim lines of the stricter ones.
+var (
+	formatNames = []string{"json", "clf", "klog", "syslog", "logfmt"}
+	formats     = map[string]inputFormat{
+		"json":   {detect: isJSON, parse: parseJSON},
+		"clf":    {detect: isCLF, parse: textFormat(parseCLF)},
+		"klog":   {detect: isKlog, parse: textFormat(parseKlog)},
+		"syslog": {detect: isSyslog, parse: textFormat(parseSyslog)},
+		"logfmt": {detect: isLogfmt, parse: parseLogfmt},
+	}
+)
+
+// detector keeps track of which format the lines of a source look like.
+type detector struct {
+	lines  int
+	counts map[string]int
+	format string
+}
+
+// the format detector of each source.
+var detectors = make(map[string]*detector)
+
+// checks the name of a format given with the -format option.
+func checkFormat(format string) error {
+	if _, ok := formats[format]; !ok && format != "auto" {
+		return fmt.Errorf("unknown -format %q", format)
+	}
+	return nil
+}
+
+// formatOf returns the format of the line read from src.  unless a format
+// was chosen with -format, the first lines of each source are checked
+// against every format and the one matched the most is used from then on.
+// a line of a json or logfmt source that is in the other of the two is read
+// in its own format, so streams that mix them display uniformly.
+func formatOf(src string, b []byte, format string) string {
+	if format != "auto" {
+		return format
+	}
+	format = sourceFormat(src, b)
+	switch {
+	case format == "json" && !isJSON(b) && isLogfmtEntry(b, &configOf(src).logfmt):
+		return "logfmt"
+	case format == "logfmt" && isJSON(b):
+		return "json"
+	}
+	return format
+}
+
+// sourceFormat returns the format detected for the source.
+func sourceFormat(src string, b []byte) string {
+	d, ok := detectors[src]
+	if !ok {
+		d = &detector{counts: make(map[string]int), format: "json"}
+		detectors[src] = d
+	}
+	if d.lines >= detectLines || len(b) == 0 {
+		return d.format
+	}
+
+	d.lines++
+	for _, name := range formatNames {
+		if formats[name].detect(b) {
+			d.counts[name]++
+			break
+		}
+	}
+
+	best := 0
+	for _, name := range formatNames {
+		if d.counts[name] > best {
+			best = d.counts[name]
+			d.format = name
+		}
+	}
+
+	return d.format
+}
+
+// adapts the parser of a text format, which names the fields itself so the
+// field keys of the source do not apply.
+func textFormat(fn func(e *Entry, b []byte) error) func(*Entry, []byte, *sourceConfig) error {
+	return func(e *Entry, b []byte, _ *sourceConfig) error {
+		return fn(e, b)
+	}
+}
+
+// parses a line of the source into e without displaying it, returning false
+// when the line is not a log entry.
+func parseEntry(src string, b []byte, e *Entry) bool {
+	cfg := configOf(src)
+	format := formatOf(src, b, cfg.format)
+	if format == "json" && !isJSON(b) {
+		return false
+	}
+	return formats[format].parse(e, b, cfg) == nil
+}
+
+// returns true if the line looks like a json object.
+func isJSON(b []byte) bool {
+	return len(b) > 0 && b[0] == '{'
+}
+
+// sets a field parsed from a text format unless it is empty or '-', which
+// syslog and the common log format use for missing values.
+func setTextField(e *Entry, key string, val []byte) {
+	if len(val) > 0 && string(val) != "-" {
+		e.Fields[key] = string(val)
+	}
+}
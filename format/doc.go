@@ -0,0 +1,14 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+
+// Package format parses json log lines and pretty prints them the way the
+// glogv command does, so the formatting can be used by other tools and test
+// harnesses.  the command renders the standard fields and the key/values
+// with the same Colors and Parser and adds its own features on top, like
+// highlighting and stack traces, which a Formatter does not have.
+//
+//	f, err := format.New(format.Options{Colors: format.DefaultColors})
+//	if err != nil {
+//		return err
+//	}
+//	line, err := f.FormatLine([]byte(`{"level":"info","message":"hello"}`))
+package format
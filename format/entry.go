@@ -0,0 +1,289 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Entry is a parsed log line.
+type Entry struct {
+	Time     time.Time      // zero if the line has no valid time.
+	Level    string         // lower case and always one of Levels.
+	RawLevel string         // the level in lower case if it is not one of Levels and defaulted to info.
+	Message  string         // the message, may be empty.
+	Error    string         // the error, may be empty.
+	Fields   map[string]any // the remaining key/values.
+	Raw      []byte         // the line as it was read, only valid until the next line.
+	Source   string         // where the line was read from.
+	LineNo   int            // the line number within the source, starting at 1, or 0 if not known.
+}
+
+// Levels are the names of the levels an entry can have.
+var Levels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
+
+// IsLevel returns true if s is one of Levels.
+func IsLevel(s string) bool {
+	for _, l := range Levels {
+		if s == l {
+			return true
+		}
+	}
+	return false
+}
+
+// levelAliases are the other names of levels, like the WARNING and CRITICAL
+// severities of Google Cloud and python.  they are mapped the same way as the
+// syslog severities.
+var levelAliases = map[string]string{
+	"default":   "info",
+	"notice":    "info",
+	"warning":   "warn",
+	"critical":  "fatal",
+	"alert":     "fatal",
+	"emergency": "fatal",
+}
+
+// NormalizeLevel returns the lower case version of the level, or the level
+// of an alias.  levels are usually already lower case so this avoids
+// allocating a new string in the common case.
+func NormalizeLevel(s string) string {
+	if IsLevel(s) {
+		return s
+	}
+	s = strings.ToLower(s)
+	if l, ok := levelAliases[s]; ok {
+		return l
+	}
+	return s
+}
+
+// LevelLabel returns the three letter label displayed for the level.
+func LevelLabel(s string) string {
+	switch s {
+	case "info":
+		return "INF"
+	case "warn":
+		return "WRN"
+	case "debug":
+		return "DBG"
+	case "error":
+		return "ERR"
+	case "panic", "fatal", "trace":
+		return "PNC"
+	default:
+		return "???"
+	}
+}
+
+// Parser moves the standard logging fields of a line into an Entry.
+type Parser struct {
+	Keys          Keys          // the keys of the standard fields.
+	KeepNested    bool          // keep nested objects instead of flattening them into dotted keys.
+	NumericLevels NumericLevels // the levels of numbers, DefaultNumericLevels if nil.
+
+	// TimeLayouts are tried in order to parse a time that is not RFC 3339 or
+	// a number, in TimeZone if it has no zone or the local zone if nil.
+	TimeLayouts []string
+	TimeZone    *time.Location
+
+	// TimeNames, if set, translates the month and day names of a time to
+	// english before it is parsed with the TimeLayouts.
+	TimeNames *strings.Replacer
+}
+
+// ParseJSON unmarshals a json log line into e.Fields and then moves the
+// standard logging fields out of it.  e.Fields must not be nil.
+func (p *Parser) ParseJSON(e *Entry, b []byte) error {
+	clear(e.Fields)
+	if err := json.UnmarshalNoEscape(b, &e.Fields); err != nil {
+		return err
+	}
+	p.Extract(e)
+	return nil
+}
+
+// Extract moves the standard logging fields out of e.Fields.
+func (p *Parser) Extract(e *Entry) {
+	e.Time = time.Time{}
+	e.Level = ""
+	e.RawLevel = ""
+	e.Message = ""
+	e.Error = ""
+
+	// nested objects are flattened first so the keys may be dotted paths.
+	if !p.KeepNested {
+		Flatten(e.Fields)
+	}
+
+	levels := p.NumericLevels
+	if levels == nil {
+		levels = DefaultNumericLevels
+	}
+
+	// a time or level of an unexpected type, like an object, is left with the
+	// other fields so it is still shown.
+	if k, val, ok := lookup(e.Fields, p.Keys.Time); ok {
+		if e.Time = p.toTime(val); !e.Time.IsZero() || val == nil {
+			delete(e.Fields, k)
+		}
+	}
+	if k, val, ok := lookup(e.Fields, p.Keys.Level); ok {
+		switch v := val.(type) {
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				e.Level = levels.Level(n)
+			} else {
+				e.Level = NormalizeLevel(v)
+			}
+			delete(e.Fields, k)
+		case float64:
+			e.Level = levels.Level(v)
+			delete(e.Fields, k)
+		case nil:
+			delete(e.Fields, k)
+		}
+	}
+
+	// a message that is not a string, like a number or an object, is shown
+	// as it was written.
+	if k, val, ok := lookup(e.Fields, p.Keys.Message); ok {
+		e.Message = textOf(val)
+		delete(e.Fields, k)
+	}
+	if k, val, ok := lookup(e.Fields, p.Keys.Error); ok {
+		if s, ok := scalarText(val); ok {
+			e.Error = s
+			delete(e.Fields, k)
+		}
+	}
+
+	// if level is unknown, set it to default
+	if !IsLevel(e.Level) {
+		e.RawLevel = e.Level
+		e.Level = "info"
+	}
+}
+
+// textOf returns a value as text, a string as is and anything else the way
+// it is written in json.  null is empty.
+func textOf(val any) string {
+	if s, ok := scalarText(val); ok {
+		return s
+	}
+	if val == nil {
+		return ""
+	}
+	return string(AppendValue(nil, val, false))
+}
+
+// scalarText returns a string, number or bool as text, and false for
+// anything else.
+func scalarText(val any) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case float64:
+		return string(AppendNumber(nil, v, false)), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// returns the key and value of the first of the keys found in the map.
+func lookup(m map[string]any, keys []string) (string, any, bool) {
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// Flatten replaces the nested objects of fields with dotted keys, so
+// {"http":{"method":"GET"}} becomes http.method=GET.
+func Flatten(fields map[string]any) {
+	for k, v := range fields {
+		if m, ok := v.(map[string]any); ok && len(m) > 0 {
+			delete(fields, k)
+			flattenInto(fields, k, m)
+		}
+	}
+}
+
+func flattenInto(fields map[string]any, prefix string, m map[string]any) {
+	for k, v := range m {
+		key := prefix + "." + k
+		if n, ok := v.(map[string]any); ok && len(n) > 0 {
+			flattenInto(fields, key, n)
+			continue
+		}
+		fields[key] = v
+	}
+}
+
+// toTime converts a time with ToTime, or with the first of the TimeLayouts
+// that parses it.
+func (p *Parser) toTime(val any) time.Time {
+	t := ToTime(val)
+	s, ok := val.(string)
+	if !t.IsZero() || !ok {
+		return t
+	}
+	if p.TimeNames != nil {
+		s = p.TimeNames.Replace(s)
+	}
+	loc := p.TimeZone
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range p.TimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t
+		}
+	}
+	return t
+}
+
+// ToTime converts a RFC 3339 string or an epoch number, which may also be
+// given as a string, to a time.  the zero time is returned for anything else.
+func ToTime(val any) time.Time {
+	switch v := val.(type) {
+	case float64:
+		return EpochTime(v)
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return EpochTime(f)
+		}
+	}
+	return time.Time{}
+}
+
+// EpochTime converts a number of seconds, milliseconds, microseconds or
+// nanoseconds since the epoch to a time, picking the unit by how big the
+// number is.  seconds may have a fraction, like 1700000000.123.
+func EpochTime(f float64) time.Time {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return time.Time{}
+	}
+	switch abs := math.Abs(f); {
+	case abs < 1e11:
+		// a float64 only has about microsecond precision for seconds.
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3)
+	case abs < 1e14:
+		return time.UnixMilli(int64(f))
+	case abs < 1e17:
+		return time.UnixMicro(int64(f))
+	default:
+		return time.Unix(0, int64(f))
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotJSON is returned by FormatLine for lines that are not a json object.
+var ErrNotJSON = errors.New("not a json object")
+
+// Colors are the escape sequences a Formatter colors the parts of a line
+// with.  the zero value formats lines without colors.
+type Colors struct {
+	Levels map[string]string // colors of the message and values by level.
+	Labels map[string]string // colors of the level label, defaults to Levels.
+	Time   string
+	Tag    string // the color of the keys.
+	Info   string // the color of info messages and values.
+	Reset  string // appended after each colored part.
+}
+
+// DefaultColors are the colors of the glogv command.
+var DefaultColors = Colors{
+	Levels: map[string]string{
+		"info":  "\033[32m",
+		"warn":  "\033[33m",
+		"debug": "\033[36m",
+		"error": "\033[31m",
+		"panic": "\033[35m",
+		"fatal": "\033[35m",
+		"trace": "\033[36m",
+	},
+	Time:  "\033[90m",
+	Tag:   "\033[90m",
+	Info:  "\033[37m",
+	Reset: "\033[0m",
+}
+
+// LevelColor returns the color of the message and values of an entry at the
+// level, which is Info for info entries.
+func (c *Colors) LevelColor(level string) string {
+	if level == "info" {
+		return c.Info
+	}
+	return c.Levels[level]
+}
+
+// ValueColor returns the color of the value of a key in an entry whose other
+// values are in clr.  errors are always in the color of the error level.
+func (c *Colors) ValueColor(key, clr string) string {
+	if strings.EqualFold(key, "error") {
+		return c.Levels["error"]
+	}
+	return clr
+}
+
+// AppendLevel appends a space and the label of the level.  the label is reset
+// so a background color does not run into the message.
+func (c *Colors) AppendLevel(b []byte, level string) []byte {
+	clr, ok := c.Labels[level]
+	if !ok {
+		clr = c.Labels["info"]
+	}
+	b = append(b, ' ')
+	b = append(b, clr...)
+	b = append(b, LevelLabel(level)...)
+	return append(b, c.Reset...)
+}
+
+// AppendKey appends a space and the key of a field followed by '=', the
+// color of the value is appended next.
+func (c *Colors) AppendKey(b []byte, key string) []byte {
+	b = append(b, ' ')
+	b = append(b, c.Tag...)
+	b = append(b, key...)
+	return append(b, '=')
+}
+
+// Options configure a Formatter.  the zero value formats zerolog lines
+// without colors.
+type Options struct {
+	Colors     Colors
+	Keys       Keys           // the keys of the standard fields, StdKeys if empty.
+	TimeFormat string         // a time layout, 03:04PM if empty.
+	TimeZone   *time.Location // the zone times are shown in, the zone of the line if nil.
+	KeepNested bool           // show nested objects as json instead of dotted keys.
+	Exact      bool           // never show numbers with exponents.
+
+	// NumericLevels are the level names of numbers, DefaultNumericLevels if nil.
+	NumericLevels NumericLevels
+
+	// TimeLayouts parse times that are not RFC 3339 or a number, see Parser.
+	TimeLayouts []string
+
+	// Hide and Only are glob patterns of the keys that are left out and the
+	// only keys that are shown.
+	Hide []string
+	Only []string
+
+	// Filter, if set, is called with each parsed entry and lines it returns
+	// false for are left out.
+	Filter func(e *Entry) bool
+}
+
+// Formatter pretty prints json log lines.  a Formatter reuses its buffers
+// and is not safe for concurrent use.
+type Formatter struct {
+	opts   Options
+	parser Parser
+	entry  Entry
+	keys   []string
+	shown  map[string]bool
+	buf    []byte
+}
+
+// New returns a Formatter with the options.
+func New(opts Options) (*Formatter, error) {
+	for _, p := range append(append([]string(nil), opts.Hide...), opts.Only...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("bad key pattern %q: %w", p, err)
+		}
+	}
+	if opts.Keys.Time == nil && opts.Keys.Level == nil && opts.Keys.Message == nil && opts.Keys.Error == nil {
+		opts.Keys = StdKeys
+	}
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = "03:04PM"
+	}
+	if opts.Colors.Labels == nil {
+		opts.Colors.Labels = opts.Colors.Levels
+	}
+	return &Formatter{
+		opts: opts,
+		parser: Parser{
+			Keys:          opts.Keys,
+			KeepNested:    opts.KeepNested,
+			NumericLevels: opts.NumericLevels,
+			TimeLayouts:   opts.TimeLayouts,
+			TimeZone:      opts.TimeZone,
+		},
+		entry: Entry{Fields: make(map[string]any)},
+		shown: make(map[string]bool),
+	}, nil
+}
+
+// FormatLine formats a json log line, without a trailing newline.  the
+// returned slice is only valid until the next call.  nil is returned for
+// lines left out by the filter, and ErrNotJSON or the parse error for lines
+// that cannot be parsed.
+func (f *Formatter) FormatLine(b []byte) ([]byte, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' {
+		return nil, ErrNotJSON
+	}
+	e := &f.entry
+	if err := f.parser.ParseJSON(e, b); err != nil {
+		return nil, err
+	}
+	e.Raw = b
+	if f.opts.Filter != nil && !f.opts.Filter(e) {
+		return nil, nil
+	}
+
+	c := &f.opts.Colors
+	out := f.buf[:0]
+
+	t := e.Time
+	if f.opts.TimeZone != nil {
+		t = t.In(f.opts.TimeZone)
+	}
+	out = append(out, c.Time...)
+	out = t.AppendFormat(out, f.opts.TimeFormat)
+	out = c.AppendLevel(out, e.Level)
+
+	clr := c.LevelColor(e.Level)
+	if e.Message != "" {
+		out = append(out, ' ')
+		out = append(out, clr...)
+		out = append(out, e.Message...)
+	}
+
+	// the error is sorted along with the other keys.
+	f.keys = f.keys[:0]
+	if e.Error != "" && f.show("error") {
+		f.keys = append(f.keys, "error")
+	}
+	for k := range e.Fields {
+		if f.show(k) {
+			f.keys = append(f.keys, k)
+		}
+	}
+	sort.Strings(f.keys)
+	for _, k := range f.keys {
+		out = c.AppendKey(out, k)
+		out = append(out, c.ValueColor(k, clr)...)
+		if k == "error" && e.Error != "" {
+			out = append(out, e.Error...)
+		} else {
+			out = AppendValue(out, e.Fields[k], f.opts.Exact)
+		}
+	}
+	out = append(out, c.Reset...)
+
+	f.buf = out
+	return out, nil
+}
+
+// show returns true if the key is not left out by the Hide and Only patterns.
+func (f *Formatter) show(key string) bool {
+	if f.opts.Hide == nil && f.opts.Only == nil {
+		return true
+	}
+	if show, ok := f.shown[key]; ok {
+		return show
+	}
+	show := f.opts.Only == nil || matchAny(f.opts.Only, key)
+	if show && matchAny(f.opts.Hide, key) {
+		show = false
+	}
+	f.shown[key] = show
+	return show
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
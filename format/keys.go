@@ -0,0 +1,69 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package format
+
+// Keys are the keys of the standard logging fields.  each field has a list
+// of alternatives and the first one found in a line is used.
+type Keys struct {
+	Time    []string
+	Level   []string
+	Message []string
+	Error   []string
+}
+
+// StdKeys are the keys used by zerolog.
+var StdKeys = Keys{
+	Time:    []string{"time"},
+	Level:   []string{"level"},
+	Message: []string{"message"},
+	Error:   []string{"error"},
+}
+
+// Presets are the keys of json lines written by common structured loggers.
+var Presets = map[string]Keys{
+	"zerolog": StdKeys,
+	"zap": {
+		Time:    []string{"ts"},
+		Level:   []string{"level"},
+		Message: []string{"msg"},
+		Error:   []string{"error"},
+	},
+	"logrus": {
+		Time:    []string{"time"},
+		Level:   []string{"level"},
+		Message: []string{"msg"},
+		Error:   []string{"error"},
+	},
+	"slog": {
+		Time:    []string{"time"},
+		Level:   []string{"level"},
+		Message: []string{"msg"},
+		Error:   []string{"err", "error"},
+	},
+	// pino and bunyan log numeric levels, and pino times in milliseconds.
+	"pino": {
+		Time:    []string{"time"},
+		Level:   []string{"level"},
+		Message: []string{"msg"},
+		Error:   []string{"err.message", "err", "error"},
+	},
+	"bunyan": {
+		Time:    []string{"time"},
+		Level:   []string{"level"},
+		Message: []string{"msg"},
+		Error:   []string{"err.message", "err", "error"},
+	},
+	// google cloud structured logs, the message is nested in the entries
+	// exported from cloud logging.
+	"gcp": {
+		Time:    []string{"timestamp", "time"},
+		Level:   []string{"severity"},
+		Message: []string{"message", "jsonPayload.message", "textPayload"},
+		Error:   []string{"error", "jsonPayload.error"},
+	},
+	"cloudtrail": {
+		Time:    []string{"eventTime"},
+		Level:   []string{"level"},
+		Message: []string{"eventName"},
+		Error:   []string{"errorMessage", "errorCode"},
+	},
+}
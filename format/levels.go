@@ -0,0 +1,50 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package format
+
+import "sort"
+
+// LevelNumber maps a numeric level to the name of a level.
+type LevelNumber struct {
+	N    float64
+	Name string
+}
+
+// NumericLevels are the names of numeric levels, sorted by number.
+type NumericLevels []LevelNumber
+
+// DefaultNumericLevels are the levels of bunyan and pino.
+var DefaultNumericLevels = NumericLevels{
+	{10, "trace"},
+	{20, "debug"},
+	{30, "info"},
+	{40, "warn"},
+	{50, "error"},
+	{60, "fatal"},
+}
+
+// Level returns the name of the highest level at or below n, custom levels
+// in between the standard ones get the name of the one below.
+func (levels NumericLevels) Level(n float64) string {
+	name := ""
+	for _, l := range levels {
+		if l.N > n {
+			break
+		}
+		name = l.Name
+	}
+	return name
+}
+
+// With returns a copy of the levels with the name of n added or replaced.
+func (levels NumericLevels) With(n float64, name string) NumericLevels {
+	out := append(NumericLevels(nil), levels...)
+	i := sort.Search(len(out), func(i int) bool { return out[i].N >= n })
+	if i < len(out) && out[i].N == n {
+		out[i].Name = name
+		return out
+	}
+	out = append(out, LevelNumber{})
+	copy(out[i+1:], out[i:])
+	out[i] = LevelNumber{n, name}
+	return out
+}
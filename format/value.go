@@ -0,0 +1,41 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+// AppendValue appends a json value.  strings are appended as is without
+// quotes, numbers without exponents unless they are very large or small, or
+// never if exact is set, and arrays and objects as compact json.
+func AppendValue(b []byte, v any, exact bool) []byte {
+	switch val := v.(type) {
+	case string:
+		return append(b, val...)
+	case float64:
+		return AppendNumber(b, val, exact)
+	case bool:
+		return strconv.AppendBool(b, val)
+	case nil:
+		return append(b, "null"...)
+	default:
+		js, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Append(b, val)
+		}
+		return append(b, js...)
+	}
+}
+
+// AppendNumber appends a json number, exact numbers never use exponents so
+// they always look the same.
+func AppendNumber(b []byte, f float64, exact bool) []byte {
+	if abs := math.Abs(f); exact || abs == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.AppendFloat(b, f, 'f', -1, 64)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, 64)
+}
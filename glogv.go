@@ -3,19 +3,20 @@ package main
 
 import (
 	"bufio"
-	"context"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
+	_ "time/tzdata" // -tz also works where there is no zoneinfo, like windows.
 
-	"github.com/goccy/go-json"
-	"github.com/klauspost/compress/gzip"
+	"github.com/cwbriscoe/glogv/format"
 )
 
 const (
@@ -36,306 +37,976 @@ var (
 	colorWhite  = "\033[37m"
 )
 
-// default colors by level.
-var color = map[string]string{
-	"info":  colorGreen,
-	"warn":  colorYellow,
-	"debug": colorCyan,
-	"error": colorRed,
-	"panic": colorPurple,
-	"fatal": colorPurple,
-	"trace": colorCyan,
-}
+// default colors by level, those of the format package.
+var color = maps.Clone(format.DefaultColors.Levels)
+
+// default colors of the level label.
+var labelColor = color
 
 // other default colors.
 var (
-	timeColor = colorGray
-	tagColor  = colorGray
-	infoColor = colorWhite
+	timeColor = format.DefaultColors.Time
+	tagColor  = format.DefaultColors.Tag
+	infoColor = format.DefaultColors.Info
+	warnColor = colorYellow
 )
 
-var timeFormat = "03:04PM"
+// currentColors returns the colors of the theme, the config file and -color
+// for the format package to render with.
+func currentColors() format.Colors {
+	return format.Colors{
+		Levels: color,
+		Labels: labelColor,
+		Time:   timeColor,
+		Tag:    tagColor,
+		Info:   infoColor,
+		Reset:  colorReset,
+	}
+}
+
+// dimColor is used for lines passed through as is when -dim is set.
+var dimColor = "\033[2m"
 
-// this struct will be used to marshall the json file into key/values.
-type keyValues struct {
-	Map map[string]any `json:"-"`
+// alarmColor is the background of fatal and panic entries when -alarm is set.
+var alarmColor = "\033[41m"
+
+// clearLine fills the rest of the terminal line with the current background.
+const clearLine = "\033[K"
+
+// unixTime is the -time-format preset of seconds since the epoch.
+const unixTime = "unix"
+
+// named time formats that can be selected with the -time-format option, any
+// other value is used as a go time layout.
+var timePresets = map[string]string{
+	"kitchen": "03:04PM",
+	"rfc3339": time.RFC3339,
+	"iso":     "2006-01-02T15:04:05.000Z",
+	"unix":    unixTime,
 }
 
+var timeFormat = timePresets["kitchen"]
+
+// the time zone times are displayed in, nil for local time.
+var timeZone *time.Location
+
+// colorsOn is set when the output is colored, which a reload of the config
+// file keeps to.
+var colorsOn bool
+
+// fixed width time format used by -canonical.
+const canonicalTime = "2006-01-02T15:04:05.000000000Z"
+
 // this string slice will store keys that will be sorted before display.
 var keys = make([]string, 0, maxKeys)
 
+// the reformatted line, it is reused for every line to avoid allocating a
+// new one.
+var line = make([]byte, 0, 4096)
+
+// output is buffered and flushed on exit, or after every line when following
+// or reading stdin so lines show up as soon as they are read.
+var (
+	out       = bufio.NewWriterSize(os.Stdout, 64*1024)
+	flushEach bool
+)
+
 // cmdline options.
-var tailFile = flag.Bool("tail", false, "tail the file(s) provided")
+var (
+	tailFile     = flag.Bool("tail", false, "tail the file(s) provided")
+	timePreset   = flag.String("time-format", "kitchen", "time format to display (kitchen, rfc3339, iso, unix or a go layout)")
+	utcTime      = flag.Bool("utc", false, "display times in UTC instead of local time")
+	tzName       = flag.String("tz", "", "display times in the given time zone, like America/Chicago")
+	seqKey       = flag.String("sequence-key", "", "warn on gaps or resets in the given counter field")
+	checkOrder   = flag.Bool("out-of-order", false, "warn when a timestamp goes backwards")
+	orderSlack   = flag.Duration("order-tolerance", 0, "how far back a timestamp may go before -out-of-order warns")
+	retry        = flag.Bool("retry", false, "keep trying to open a followed file that does not exist yet")
+	followRetry  = flag.Bool("F", false, "same as -tail -retry")
+	cpFile       = flag.String("checkpoint", "", "file used to save and resume the -tail position of each file")
+	themeName    = flag.String("theme", "default", "color theme (default, light, solarized, deuteranopia, tritanopia)")
+	style        = flag.String("style", "plain", "how the time and level are rendered (plain, segments)")
+	timeKey      = flag.String("time-key", "", "comma separated keys of the time field (default time)")
+	levelNums    = flag.String("level-numbers", "", "comma separated number=level pairs added to the numeric levels (10=trace ... 60=fatal)")
+	levelKey     = flag.String("level-key", "", "comma separated keys of the level field (default level)")
+	msgKey       = flag.String("msg-key", "", "comma separated keys of the message field (default message)")
+	errorKey     = flag.String("error-key", "", "comma separated keys of the error field (default error)")
+	inputFmt     = flag.String("format", "auto", "input format (auto, json, logfmt, klog, syslog, clf)")
+	preset       = flag.String("preset", "zerolog", "field names of a structured logger (zerolog, zap, logrus, slog, pino, bunyan, gcp, cloudtrail)")
+	canonical    = flag.Bool("canonical", false, "deterministic output without colors for golden file tests")
+	cpuProfile   = flag.String("cpuprofile", "", "write a cpu profile to the given file")
+	memProfile   = flag.String("memprofile", "", "write a memory profile to the given file on exit")
+	background   = flag.String("background", "auto", "terminal background (auto, light, dark)")
+	colorMode    = flag.String("color", "auto", "when to use colors (auto, always, never), auto honors NO_COLOR")
+	configFile   = flag.String("config", "", "config file to load instead of ~/.config/glogv/config.toml")
+	profileName  = flag.String("profile", "", "also apply the [profile.<name>] table of the config file")
+	dumpCfg      = flag.Bool("dump-config", false, "print the effective configuration and exit")
+	jsonOnly     = flag.Bool("json-only", false, "drop lines that are not log entries instead of printing them as is")
+	dim          = flag.Bool("dim", false, "dim lines that are not log entries")
+	tuiOn        = flag.Bool("tui", false, "browse the lines in a scrollable view with search and level toggles while using -tail")
+	splitView    = flag.Bool("split", false, "show the first source and the others side by side in the -tui, or one source twice")
+	panelOn      = flag.Bool("panel", false, "show rolling counts at the bottom of the terminal, or on the right of the -tui, while using -tail")
+	panelKey     = flag.String("panel-key", "", "field whose most common values are shown in the -panel")
+	recordFile   = flag.String("record", "", "save the lines read while using -tail to a session file for glogv replay")
+	dropLines    = flag.Bool("drop", false, "drop lines and show how many instead of waiting when -tail output falls behind")
+	statusOn     = flag.Bool("status", false, "show the rate, time since the last line and bytes behind of each source while using -tail")
+	panelWindow  = flag.Duration("panel-window", 5*time.Minute, "how far back the -panel counts go")
+	multiline    = flag.Bool("multiline", false, "join json objects that are pretty printed over several lines")
+	hideKeys     = flag.String("hide", "", "comma separated glob patterns of fields that are not shown")
+	onlyKeys     = flag.String("only", "", "comma separated glob patterns of the only fields that are shown")
+	expand       = flag.Bool("expand", false, "show nested objects as an indented block under the entry instead of dotted keys")
+	rollupEvery  = flag.Duration("rollup", 0, "print one summary line per window of the given length instead of every entry")
+	latencyKey   = flag.String("latency-key", "latency,duration,elapsed,took", "comma separated keys of the latency field used by -rollup")
+	correlateKey = flag.String("correlate", "", "mark entries sharing a value of the given field with the same numbered color")
+	alarm        = flag.Bool("alarm", false, "render fatal and panic entries on a red background across the whole line")
+	previewLines = flag.Int("preview", 0, "only show the first and last n lines of each file and its time span")
+)
 
 func init() {
 	flag.BoolVar(tailFile, "t", false, "")
 }
 
+// returns true if the named flag was set on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func main() {
-	// parse flags
-	flag.Parse()
+	// parse flags, a bad flag exits with errorExitCode rather than the 2 of
+	// the flag package, which -exit-by-severity uses for errors.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(errorExitCode)
+	}
 	files := flag.Args()
+	// set as flags so they count as given on the command line.
+	if *followRetry {
+		flag.Set("tail", "true")
+		flag.Set("retry", "true")
+	}
+
+	// defaults from the config file do not override the command line.
+	if err := loadConfigFile(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+
+	if err := setOutput(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+
+	// colors are only used on a terminal unless asked for.  a windows
+	// console that can not show them gets none, and neither does json.
+	useColor := !jsonOutput
+	switch *colorMode {
+	case "always":
+		enableColors(os.Stdout)
+	case "never":
+		useColor = false
+	case "auto":
+		useColor = useColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout) && enableColors(os.Stdout)
+	default:
+		fmt.Printf("unknown -color %q\n", *colorMode)
+		os.Exit(errorExitCode)
+	}
+
+	// the terminal is only asked for its background when colors are used.
+	theme := *themeName
+	colors, err := colorOverrides()
+	if err == nil && useColor {
+		theme, err = pickTheme()
+	}
+	if err == nil {
+		err = applyTheme(theme)
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	applyColors(colors)
+
+	// the colors are dumped as the theme has them, even when they are off.
+	var dumpColors map[string]string
+	if *dumpCfg {
+		dumpColors = colorTable()
+	}
+	if !useColor {
+		disableColors()
+	}
+	colorsOn = useColor && !*canonical
+	setCallerLink()
+
+	if err := setDefaultConfig(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+
+	if err := setKeyFilters(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setNumericLevels(*levelNums); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	latencyKeys = strings.Split(*latencyKey, ",")
+	if err := setValueFilters(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if *statsOn {
+		summ = newSummary(*statsBy)
+	}
+	if err := setFailOn(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setSLOs(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setExpects(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setStaticFields(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+
+	if *style != "plain" && *style != "segments" {
+		fmt.Printf("unknown -style %q\n", *style)
+		os.Exit(errorExitCode)
+	}
+
+	// resolve the time format, the iso preset is always rendered in UTC.
+	// anything that is not a preset must at least look like a layout.
+	layout, ok := timePresets[*timePreset]
+	if !ok {
+		if !strings.ContainsAny(*timePreset, "0123456789") {
+			fmt.Printf("unknown -time-format %q\n", *timePreset)
+			os.Exit(errorExitCode)
+		}
+		layout = *timePreset
+	}
+	timeFormat = layout
+	if *timePreset == "iso" {
+		*utcTime = true
+	}
+
+	// resolve the time zone.
+	if *tzName != "" {
+		if *utcTime {
+			fmt.Printf("-tz can not be used with -utc or -time-format iso\n")
+			os.Exit(errorExitCode)
+		}
+		loc, err := time.LoadLocation(*tzName)
+		if err != nil {
+			fmt.Printf("unknown -tz %q\n", *tzName)
+			os.Exit(errorExitCode)
+		}
+		timeZone = loc
+	}
+	if *utcTime {
+		timeZone = time.UTC
+	}
+
+	// canonical output overrides the display options.
+	if *canonical {
+		disableColors()
+		timeFormat = canonicalTime
+		timeZone = time.UTC
+		*style = "plain"
+	}
+
+	// times without a zone are in the zone times are displayed in.
+	if err := setTimeParse(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setTimeLocale(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if err := setTimeRange(time.Now()); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(errorExitCode)
+	}
+
+	if *dumpCfg {
+		if err := dumpConfig(dumpColors); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(errorExitCode)
+		}
+		return
+	}
 
 	// make sure there is a file provided if the -tail option is set
 	if *tailFile && len(files) == 0 {
 		fmt.Printf("-tail option used without a file being provided\n")
 		os.Exit(errorExitCode)
 	}
+	if (*panelOn || *statusOn) && !*tailFile {
+		fmt.Printf("-panel and -status can only be used with -tail\n")
+		os.Exit(errorExitCode)
+	}
+	if *tuiOn && !*tailFile {
+		fmt.Printf("-tui can only be used with -tail\n")
+		os.Exit(errorExitCode)
+	}
+	if *splitView && !*tuiOn {
+		fmt.Printf("-split can only be used with -tui\n")
+		os.Exit(errorExitCode)
+	}
+	if *filterCommands && (!*tailFile || *tuiOn) {
+		fmt.Printf("-commands can only be used with -tail and without -tui\n")
+		os.Exit(errorExitCode)
+	}
+	if *recordFile != "" && !*tailFile {
+		fmt.Printf("-record can only be used with -tail\n")
+		os.Exit(errorExitCode)
+	}
+	if *alertsFile != "" {
+		if !*tailFile {
+			fmt.Printf("-alerts can only be used with -tail\n")
+			os.Exit(errorExitCode)
+		}
+		if err := loadAlerts(*alertsFile); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(errorExitCode)
+		}
+	}
+	if *mergeOn && (*tailFile || *previewLines > 0) {
+		fmt.Printf("-merge can not be used with -tail or -preview\n")
+		os.Exit(errorExitCode)
+	}
+	if *fileTableOn && *tailFile {
+		fmt.Printf("-file-table can not be used with -tail\n")
+		os.Exit(errorExitCode)
+	}
+	if *tailFile && *previewLines > 0 {
+		fmt.Printf("-preview can not be used with -tail\n")
+		os.Exit(errorExitCode)
+	}
+	if *headLines < 0 {
+		fmt.Printf("-head must not be negative\n")
+		os.Exit(errorExitCode)
+	}
+	if *headLines > 0 && lastLimit.isSet() {
+		fmt.Printf("-head can not be used with -last\n")
+		os.Exit(errorExitCode)
+	}
+	if (*headLines > 0 || lastLimit.isSet()) && (*tailFile || *previewLines > 0 || *mergeOn) {
+		fmt.Printf("-head and -last can not be used with -tail, -preview or -merge\n")
+		os.Exit(errorExitCode)
+	}
 
-	// check for tail mode if flag set.
-	if *tailFile {
-		if err := tail(files); err != nil {
+	// check for subcommands.
+	if len(files) > 0 && files[0] == "serve-grpc" {
+		if len(files) > 2 {
+			fmt.Printf("usage: glogv serve-grpc [host]:port\n")
+			os.Exit(errorExitCode)
+		}
+		// the address may be left out when the sockets are passed by systemd.
+		addr := ""
+		if len(files) == 2 {
+			addr = files[1]
+		}
+		if err := serveGRPC(addr); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(errorExitCode)
+		}
+		return
+	}
+	if len(files) > 0 && files[0] == "stats" {
+		if err := runStats(files[1:]); err != nil {
+			fmt.Printf("error: %v\n", err)
+			os.Exit(errorExitCode)
+		}
+		return
+	}
+	if len(files) > 0 && files[0] == "schema" {
+		if err := runSchema(files[1:]); err != nil {
 			fmt.Printf("error: %v\n", err)
 			os.Exit(errorExitCode)
 		}
 		return
 	}
 
-	// check for cat mode if not tail mode and file provided.
-	if len(files) > 0 {
-		if err := cat(files); err != nil {
+	if len(files) > 0 && files[0] == "replay" {
+		err := runReplay(files[1:])
+		if flushErr := out.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
 			fmt.Printf("error: %v\n", err)
 			os.Exit(errorExitCode)
 		}
 		return
 	}
 
-	// otherwise, scan stdin.
-	if err := scan(); err != nil {
+	stop, err := startProfile()
+	if err != nil {
 		fmt.Printf("error: %v\n", err)
 		os.Exit(errorExitCode)
 	}
-}
 
-// scan continues to scan stdin until EOF.
-func scan() error {
-	scanner := bufio.NewScanner(os.Stdin)
-
-	// loop until EOF.
-	for scanner.Scan() {
-		reformat(scanner.Bytes())
+	// the pager is waited for after the output is flushed to it.
+	wait := func() error { return nil }
+	paged, err := usePager(files)
+	if err == nil && paged {
+		wait, err = startPager()
+	}
+	if err == nil {
+		err = openTees()
+	}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(errorExitCode)
 	}
 
-	return scanner.Err()
+	err = run(files)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	// quitting the pager before the end is not an error.
+	if errors.Is(err, errPagerQuit) {
+		err = nil
+	}
+	if teeErr := closeTees(); err == nil {
+		err = teeErr
+	}
+	if waitErr := wait(); err == nil {
+		err = waitErr
+	}
+	if stopErr := stop(); err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(errorExitCode)
+	}
+	if *exitBySeverity && severityExit != 0 {
+		os.Exit(severityExit)
+	}
+	if failSeen {
+		os.Exit(failOnExitCode)
+	}
 }
 
-// tail will run the linux tail command and log the output
-func tail(files []string) error {
-	// check if file(s) exists first
-	for _, file := range files {
-		if _, err := os.Stat(file); err != nil {
-			return err
-		}
+// run tails or cats the file(s), or scans stdin if no files are provided.
+func run(files []string) error {
+	if len(files) == 0 {
+		files = []string{"-"}
 	}
 
-	args := []string{"--follow=name"}
-	args = append(args, files...)
-
-	cmd := exec.CommandContext(context.Background(), "tail", args...)
-
-	stdout, err := cmd.StdoutPipe()
+	srcs, err := parseSources(files)
 	if err != nil {
 		return err
 	}
-
-	var wg sync.WaitGroup
-	wg.Add(1)
-
-	scanner := bufio.NewScanner(stdout)
-	go func() {
-		for scanner.Scan() {
-			reformat(scanner.Bytes())
+	// the lines of a -tail glob are labeled with the files that match it.
+	labels := make([]string, 0, len(srcs))
+	globbed := false
+	for _, s := range srcs {
+		if g, ok := s.(*globSource); ok {
+			labels = append(labels, g.files...)
+			globbed = true
+		} else {
+			labels = append(labels, s.Label())
 		}
-		wg.Done()
-	}()
-
-	if err = cmd.Start(); err != nil {
+	}
+	if err := setLabels(labels, globbed); err != nil {
 		return err
 	}
+	if *fileTableOn {
+		startFileTable(labels)
+	}
+	for _, s := range srcs {
+		if _, ok := s.(*stdinSource); ok {
+			flushEach = true
+		}
+	}
 
-	if err := scanner.Err(); err != nil {
-		return err
+	// check for tail mode if flag set.
+	if *tailFile {
+		flushEach = true
+		err = tail(srcs)
+		flushDocs()
+	} else if *previewLines > 0 {
+		err = preview(srcs)
+	} else if *mergeOn {
+		err = merge(srcs)
+	} else {
+		err = cat(srcs)
 	}
 
-	wg.Wait()
+	// the last -rollup window is not complete, but still show it.
+	flushDedup()
+	finishRollup()
 
-	return cmd.Wait()
+	// the reports go to stderr so they are not mixed in with -output json.
+	var report io.Writer = out
+	if jsonOutput {
+		out.Flush()
+		report = os.Stderr
+	}
+	if summ != nil {
+		summ.print(report)
+	}
+	if fileTable != nil {
+		printFileTable(report)
+	}
+	return err
 }
 
-// cat will read the given file(s) and reformat it
-func cat(files []string) error {
-	fn := func(file string) error {
-		read, err := os.Open(file)
-		if err != nil {
-			return err
-		}
-		defer read.Close()
+// reformats the json log line into a prettier, more readable version.
+// src identifies where the line was read from.
+func reformat(src string, b []byte) {
+	// the lines of container log files are unwrapped first, -raw-out still
+	// writes them as they were read.
+	payload, ok := unwrapCRI(src, b)
+	if !ok {
+		holdRaw(src, b)
+		return
+	}
+	setRawLine(src, b)
+	reformatPayload(src, payload)
+}
 
-		// pick a reader based on if the file is compressed or not.
-		var scanner *bufio.Scanner
-		if filepath.Ext(file) == ".gz" {
-			gz, err := gzip.NewReader(read)
-			if err != nil {
-				return err
-			}
-			defer gz.Close()
-			scanner = bufio.NewScanner(gz)
+// reformats the log line without the wrapping of a container runtime.
+func reformatPayload(src string, b []byte) {
+	if *multiline {
+		joinLines(src, b, reformatLine)
+		return
+	}
+	reformatLine(src, b)
+}
+
+// reformats a single log entry.
+func reformatLine(src string, b []byte) {
+	lineNo := nextLineNo(src)
+
+	// first make sure the line is in the format of the source, if not print
+	// it as is.
+	cfg := configOf(src)
+	format := formatOf(src, b, cfg.format)
+	if format == "json" && !isJSON(b) {
+		reportParse(src, lineNo, "not a json object", b)
+		printPlain(src, b)
+		return
+	}
+
+	entry.Raw = b
+	entry.Source = src
+	entry.LineNo = lineNo
+	if format == "json" && isRecords(b) && unwrapRecords(b, cfg) {
+		return
+	}
+	if err := formats[format].parse(&entry, b, cfg); err != nil {
+		reportParse(src, entry.LineNo, err.Error(), b)
+		if format == "json" {
+			recoverLine(&entry, b, &cfg.json)
 		} else {
-			scanner = bufio.NewScanner(read)
+			printPlain(src, b)
 		}
+		return
+	}
 
-		// loop until EOF.
-		for scanner.Scan() {
-			reformat(scanner.Bytes())
-		}
+	render(&entry, "")
+}
 
-		return scanner.Err()
+// render displays the log entry. if note is not empty, it is displayed at
+// the end of the line.
+func render(e *Entry, note string) {
+	if e.Time.IsZero() {
+		e.Time = criTime
+	}
+	addStaticFields(e)
+	if *k8sMeta {
+		addK8sFields(e)
+	}
+	if *parseUA != "" {
+		addUserAgent(e)
+	}
+	if *resolveKeys != "" {
+		resolveFields(e)
 	}
 
-	for _, file := range files {
-		if err := fn(file); err != nil {
-			return err
+	// check the sequence counter for dropped lines.
+	if *seqKey != "" {
+		if val, ok := e.Fields[*seqKey]; ok {
+			if warning := checkSequence(e.Source, val); warning != "" {
+				printWarning(warning)
+			}
 		}
 	}
 
-	return nil
-}
+	// check for timestamps that go backwards.
+	if *checkOrder {
+		if warning := checkTimeOrder(e.Source, e.Time); warning != "" {
+			printWarning(warning)
+		}
+	}
 
-// reformats the json log line into a prettier, more readable version.
-func reformat(b []byte) {
-	var tm time.Time
-	var level, message string
+	if alertRules != nil {
+		checkAlerts(e, time.Now())
+	}
+	noteFailure(e.Level)
+	if fileTable != nil {
+		countFileEntry(e)
+	}
+	if *compareKey != "" {
+		noteGood(e)
+	}
 
-	// first make sure the log line is json, if not return without processing.
-	if string(b[:1]) != "{" {
+	// the checks above see every entry, the filters only change what is shown.
+	// entries around a -grep match are shown as context with -A, -B and -C.
+	if !keepFields(e) {
+		return
+	}
+	if !matchGrep(e) {
+		if *rollupEvery == 0 && contextWanted() {
+			alignPeek = true
+			addContext(appendEntry(line[:0], e, note), e.Source)
+			alignPeek = false
+		}
+		return
+	}
+	printContext()
+	if *exitBySeverity {
+		noteSeverity(e.Level)
+	}
+	if summ != nil {
+		summ.add(e)
+	}
+	if pnl != nil {
+		pnl.add(e)
+	}
+	writeRaw(e.Raw)
+	if *rollupEvery > 0 {
+		addRollup(e)
 		return
 	}
 
-	// marshall the current log entry into a key/value map.
-	keyVals := &keyValues{}
-	if err := json.UnmarshalNoEscape(b, &keyVals.Map); err != nil {
+	if jsonOutput {
+		line = appendJSON(line[:0], e)
+		writeLine(line, e.Source, e)
 		return
 	}
 
-	// first parse and format the standard logging fields.
-	if val, ok := keyVals.Map["time"]; ok {
-		tm, _ = time.Parse(time.RFC3339, val.(string))
+	// finally, print the prettier log entry.
+	line = appendEntry(line[:0], e, note)
+	if *dedup {
+		holdLine(line, e)
+	} else {
+		writeLine(line, e.Source, e)
+	}
+	startContext()
+}
+
+// appendEntry appends the reformatted log entry and a newline.
+func appendEntry(b []byte, e *Entry, note string) []byte {
+	// reformat the standard logging fields.
+	if *gutter {
+		b = appendGutter(b, e.Level)
 	}
-	if val, ok := keyVals.Map["level"]; ok {
-		level = strings.ToLower(val.(string))
+	b = appendLabel(b, e.Source)
+	// the segments are made of colors, so they are plain without them.
+	if *style == "segments" && colorsOn {
+		b = appendSegments(b, e.Time, e.Level)
+	} else {
+		b = appendTime(b, e.Time)
+		b = appendLevel(b, e.Level)
 	}
-	if val, ok := keyVals.Map["message"]; ok {
-		message = val.(string)
+	if *correlateKey != "" {
+		b = appendCorrelation(b, e)
+	}
+	proc := &configOf(e.Source).process
+	b = appendPrefix(b, e, proc)
+	clr := lineHighlight(e, getColor(e.Level))
+	b = appendMessage(b, e.Message, keyHighlight(e, "message", clr))
+
+	// now, parse through the remaining key/values.
+	b = appendFields(b, e, clr)
+	b = appendQuiet(b, e, proc)
+	b = appendCaller(b, e)
+	if *expand {
+		b = appendExpanded(b, e)
 	}
 
-	// if level is unknown, set it to default
-	if _, ok := color[level]; !ok {
-		level = "info"
+	if *sampling {
+		b = appendSampling(b, e)
+	}
+	if sloRules != nil {
+		b = appendSLOs(b, e)
+	}
+	if expectRules != nil {
+		b = appendExpects(b, e)
+	}
+	if note != "" {
+		b = append(b, ' ')
+		b = append(b, warnColor...)
+		b = append(b, '[')
+		b = append(b, note...)
+		b = append(b, ']')
 	}
 
-	// reformat what we have parsed so far.
-	tmStr := formatTime(tm)
-	lvlStr := formatLevel(level)
-	msgStr := formatMessage(message, level)
+	// make catastrophic entries stand out across the full width.
+	if *alarm && alarmColor != "" && (e.Level == "fatal" || e.Level == "panic") {
+		b = appendAlarm(b)
+	}
+	if *stacks {
+		b = appendStacks(b, e)
+	}
+	if *compareKey != "" {
+		b = appendComparison(b, e)
+	}
 
-	// next delete the keys we just processed from the map.
-	delete(keyVals.Map, "time")
-	delete(keyVals.Map, "level")
-	delete(keyVals.Map, "message")
+	b = append(b, colorReset...)
+	return append(b, '\n')
+}
 
-	// now, parse through the remaining key/values in the map.
-	valStr := formatMap(keyVals.Map, level)
+// appendAlarm puts the whole line on the alarm background, restoring it after
+// each color that resets it, like colorReset or the colors of a theme that
+// start with 0, and filling the remainder of the terminal line.
+func appendAlarm(b []byte) []byte {
+	alarmed := make([]byte, 0, len(b)+64)
+	alarmed = append(alarmed, alarmColor...)
+	for {
+		i := bytes.Index(b, []byte("\033["))
+		if i < 0 {
+			break
+		}
+		n := bytes.IndexFunc(b[i+2:], func(r rune) bool { return r != ';' && (r < '0' || r > '9') })
+		if n < 0 {
+			break
+		}
+		end := i + 2 + n + 1
+		alarmed = append(alarmed, b[:end]...)
+		if b[end-1] == 'm' && resetsColor(b[i+2:end-1]) {
+			alarmed = append(alarmed, alarmColor...)
+		}
+		b = b[end:]
+	}
+	alarmed = append(alarmed, b...)
+	return append(alarmed, clearLine...)
+}
 
-	// finally, print the prettier log entry.
-	fmt.Printf("%s%s%s%s\n", tmStr, lvlStr, msgStr, valStr)
+// resetsColor returns true if the parameters of a color sequence reset the
+// attributes, which an empty parameter or a 0 does unless it is part of a
+// 38 or 48 color.
+func resetsColor(params []byte) bool {
+	ps := strings.Split(string(params), ";")
+	for i := 0; i < len(ps); i++ {
+		switch ps[i] {
+		case "", "0", "00":
+			return true
+		case "38", "48", "58":
+			if i+1 < len(ps) && ps[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(ps) && ps[i+1] == "2" {
+				i += 4
+			}
+		}
+	}
+	return false
 }
 
-func getColor(l string) string {
-	var clr string
-	if l == "info" {
-		clr = infoColor
-	} else {
-		clr = color[l]
+// prints a line that is not a log entry, such as a panic or stack trace
+// interleaved with the entries, as is unless -json-only is set.
+func printPlain(src string, b []byte) {
+	if *jsonOnly || *rollupEvery > 0 || jsonOutput {
+		return
 	}
-	return clr
+	if !keepPlain(b) {
+		if contextWanted() {
+			addContext(appendPlain(line[:0], src, b), src)
+		}
+		return
+	}
+	printContext()
+	writeRaw(b)
+	line = appendPlain(line[:0], src, b)
+	writeLine(line, src, nil)
+	startContext()
 }
 
-// formats the 'time' portion of the json log line.
-func formatTime(t time.Time) string {
-	return timeColor + t.Format(timeFormat)
+// appendPlain appends a line that is not a log entry and a newline.
+func appendPlain(b []byte, src string, plain []byte) []byte {
+	if *gutter {
+		b = appendGutter(b, "")
+	}
+	b = appendLabel(b, src)
+	if *dim {
+		b = append(b, dimColor...)
+	}
+	b = append(b, plain...)
+	b = append(b, colorReset...)
+	return append(b, '\n')
 }
 
-// formats the 'level' portion of the json log line.
-func formatLevel(s string) string {
-	switch s {
-	case "info":
-		return " " + color[s] + "INF"
-	case "warn":
-		return " " + color[s] + "WRN"
-	case "debug":
-		return " " + color[s] + "DBG"
-	case "error":
-		return " " + color[s] + "ERR"
-	case "panic":
-		return " " + color[s] + "PNC"
-	case "fatal":
-		return " " + color[s] + "PNC"
-	case "trace":
-		return " " + color[s] + "PNC"
-	default:
-		return color["info"] + " ???"
+// prints a warning about the log stream on its own line.
+func printWarning(s string) {
+	flushDedup()
+	s = fmt.Sprintf("%s!! %s%s\n", warnColor, s, colorReset)
+	if jsonOutput {
+		// keep the json lines valid.
+		out.Flush()
+		os.Stderr.WriteString(s)
+		return
 	}
+	out.WriteString(s)
+	writeFormatted([]byte(s))
 }
 
-// formats the 'message' portion of the json log line.
-func formatMessage(s string, l string) string {
-	if s == "" {
-		return s
+// prints a dim notice about the log stream on its own line.
+func printNotice(s string) {
+	flushDedup()
+	s = fmt.Sprintf("%s--- %s ---%s\n", tagColor, s, colorReset)
+	if jsonOutput {
+		out.Flush()
+		os.Stderr.WriteString(s)
+		return
 	}
+	out.WriteString(s)
+	writeFormatted([]byte(s))
+}
 
-	return " " + getColor(l) + s
+func getColor(l string) string {
+	c := currentColors()
+	return c.LevelColor(l)
 }
 
-// formats the remaining key/value pairs of the json log line.
-func formatMap(m map[string]any, l string) string {
-	length := len(m)
-	// if the map is empty then return nothing.
-	if length == 0 {
-		return ""
+// formats the 'time' portion of the json log line.
+func appendTime(b []byte, t time.Time) []byte {
+	b = append(b, timeColor...)
+	return appendDisplayTime(b, t)
+}
+
+// appends the time formatted for display.
+func appendDisplayTime(b []byte, t time.Time) []byte {
+	if timeZone != nil {
+		t = t.In(timeZone)
+	}
+	if timeFormat == unixTime {
+		return strconv.AppendInt(b, t.Unix(), 10)
 	}
+	return t.AppendFormat(b, timeFormat)
+}
 
-	// compute value color
-	clr := getColor(l)
+// formats the 'level' portion of the json log line.
+func appendLevel(b []byte, s string) []byte {
+	c := currentColors()
+	return c.AppendLevel(b, s)
+}
 
-	// if there is just one value left in the map, return it now.
-	if length == 1 {
-		for k, v := range m {
-			return " " + tagColor + k + "=" + clr + v.(string)
-		}
+// formats the 'message' portion of the json log line.
+func appendMessage(b []byte, s string, clr string) []byte {
+	if s == "" {
+		return b
 	}
 
-	// there is more than 1 value in the map, so we will sort by
-	// key to get a consistent order.
+	b = append(b, ' ')
+	b = append(b, clr...)
+	return appendMatches(b, s, grepRe, clr)
+}
+
+// formats the error and the remaining key/value pairs of the json log line
+// with the values in clr.
+func appendFields(b []byte, e *Entry, clr string) []byte {
+	// if there is nothing left then return nothing.
+	if len(e.Fields) == 0 && e.Error == "" {
+		return b
+	}
+
+	// sort by key to get a consistent order, the error is sorted along with
+	// the other keys.  the process fields are shown on their own.
+	proc := &configOf(e.Source).process
 	keys = keys[:0]
-	i := 0
-	for k := range m {
+	if e.Error != "" && showKey("error") {
+		keys = append(keys, "error")
+	}
+	for k, v := range e.Fields {
+		if !showKey(k) || isNested(v) || isStack(k, v) || isSampling(k, v) || proc.has(k) || isCaller(k) {
+			continue
+		}
 		keys = append(keys, k)
-		i++
-		if i > maxKeys {
+		if len(keys) > maxKeys {
 			break
 		}
 	}
 
+	// with -align the values are padded into columns, except the last one, and
+	// the columns of the recent entries the entry does not have are blank.
+	if *alignOn && len(keys) > 0 {
+		if !alignPeek {
+			alignEntries++
+		}
+		b = appendMessagePadding(b, e)
+		keys = addAlignColumns(keys)
+	}
+
 	sort.Strings(keys)
+	orderKeys(keys, e)
 
-	var s string
-	for _, k := range keys {
-		if strings.ToLower(k) == "error" {
-			s += " " + tagColor + k + "=" + color["error"] + m[k].(string)
+	last := len(keys) - 1
+	for last >= 0 && alignMissing[keys[last]] {
+		last--
+	}
+	c := currentColors()
+	for i, k := range keys {
+		if alignMissing[k] {
+			if i < last {
+				b = appendMissingColumn(b, k)
+			}
+			continue
+		}
+		b = c.AppendKey(b, k)
+		valClr := c.ValueColor(k, clr)
+		if keyHighlights != nil {
+			valClr = keyHighlight(e, k, valClr)
+		}
+		b = append(b, valClr...)
+		start := len(b)
+		if k == "error" && e.Error != "" {
+			b = append(b, e.Error...)
+		} else if kind := humanKind(k); kind != 0 {
+			b = appendHumanized(b, kind, e.Fields[k])
 		} else {
-			s += " " + tagColor + k + "=" + clr + fmt.Sprint(m[k])
+			b = appendValue(b, e.Fields[k])
+		}
+		if k == grepField && grepKeyRe != nil {
+			val := string(b[start:])
+			b = appendMatches(b[:start], val, grepKeyRe, valClr)
+		}
+		if *alignOn {
+			n := visibleLen(b[start:])
+			if pad := alignWidth(k, n) - n; i < last {
+				b = appendPadding(b, pad)
+			}
 		}
 	}
 
-	return s
+	return b
+}
+
+// appends a json value.  strings are appended as is without quotes, numbers
+// without exponents unless they are very large or small, and arrays and
+// objects as compact json.
+func appendValue(b []byte, v any) []byte {
+	return format.AppendValue(b, v, *canonical)
+}
+
+// appends a json number.  -canonical never uses exponents so numbers always
+// look the same.
+func appendNumber(b []byte, f float64) []byte {
+	return format.AppendNumber(b, f, *canonical)
 }
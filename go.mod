@@ -3,6 +3,16 @@ module github.com/cwbriscoe/glogv
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/goccy/go-json v0.10.2
 	github.com/klauspost/compress v1.17.0
+	github.com/pierrec/lz4/v4 v4.1.30
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/net v0.17.0
+	golang.org/x/term v0.13.0
+)
+
+require (
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
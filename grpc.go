@@ -0,0 +1,207 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/goccy/go-json"
+	"github.com/klauspost/compress/gzip"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// largest grpc message that will be accepted.
+const maxGRPCMessage = 16 << 20
+
+// grpc status codes used by the server.
+const (
+	grpcOK            = 0
+	grpcInvalid       = 3
+	grpcUnimplemented = 12
+)
+
+// serializes reformatting of lines received by concurrent rpcs.
+var renderMu sync.Mutex
+
+// serveGRPC accepts log lines over grpc on addr and displays them as they
+// arrive.  both the glogv.Ingest/Stream rpc described in ingest.proto and the
+// OTLP logs service are supported, over plain text HTTP/2.  the sockets
+// passed by systemd socket activation are used instead of addr if there are
+// any, and the config file is reloaded on SIGHUP.
+func serveGRPC(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/glogv.Ingest/Stream", grpcHandler(ingestStream))
+	mux.HandleFunc("/opentelemetry.proto.collector.logs.v1.LogsService/Export", grpcHandler(ingestOTLP))
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: h2c.NewHandler(mux, &http2.Server{}),
+	}
+
+	listeners, err := activatedListeners()
+	if err != nil {
+		return err
+	}
+	if listeners == nil && addr == "" {
+		return errors.New("usage: glogv serve-grpc [host]:port")
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			renderMu.Lock()
+			showReload()
+			out.Flush()
+			renderMu.Unlock()
+		}
+	}()
+
+	if listeners == nil {
+		return srv.ListenAndServe()
+	}
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- srv.Serve(l)
+		}(l)
+	}
+	return <-errs
+}
+
+// an rpc reads the request messages and returns the grpc status code.
+type rpc func(src string, next func() ([]byte, error)) (int, error)
+
+// grpcHandler adapts an rpc to an http handler that does the grpc framing.
+func grpcHandler(fn rpc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+		if r.ProtoMajor != 2 {
+			w.Header().Set("Grpc-Status", fmt.Sprint(grpcUnimplemented))
+			w.Header().Set("Grpc-Message", "grpc requires HTTP/2")
+			return
+		}
+
+		gzipped := r.Header.Get("Grpc-Encoding") == "gzip"
+		next := func() ([]byte, error) {
+			return readGRPCMessage(r.Body, gzipped)
+		}
+
+		code, err := fn(r.RemoteAddr, next)
+		if code == grpcOK {
+			// every rpc returns an empty message.
+			w.Write([]byte{0, 0, 0, 0, 0})
+		}
+		w.Header().Set("Grpc-Status", fmt.Sprint(code))
+		if err != nil {
+			w.Header().Set("Grpc-Message", err.Error())
+		}
+	}
+}
+
+// ingestStream displays each Line sent on the stream.
+func ingestStream(src string, next func() ([]byte, error)) (int, error) {
+	for {
+		msg, err := next()
+		if err == io.EOF {
+			return grpcOK, nil
+		}
+		if err != nil {
+			return grpcInvalid, err
+		}
+
+		fields, err := parseProtobuf(msg)
+		if err != nil {
+			return grpcInvalid, err
+		}
+
+		var data []byte
+		label := src
+		for _, f := range fields {
+			switch {
+			case f.num == 1 && f.wire == wireBytes:
+				data = f.bytes
+			case f.num == 2 && f.wire == wireBytes && len(f.bytes) > 0:
+				label = string(f.bytes)
+			}
+		}
+
+		renderMu.Lock()
+		reformat(label, data)
+		out.Flush()
+		renderMu.Unlock()
+	}
+}
+
+// ingestOTLP displays the records of an OTLP ExportLogsServiceRequest.
+func ingestOTLP(src string, next func() ([]byte, error)) (int, error) {
+	msg, err := next()
+	if err != nil && err != io.EOF {
+		return grpcInvalid, err
+	}
+
+	records, err := decodeOTLPLogs(msg)
+	if err != nil {
+		return grpcInvalid, err
+	}
+
+	renderMu.Lock()
+	defer renderMu.Unlock()
+	for _, rec := range records {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return grpcInvalid, err
+		}
+		reformat(src, b)
+	}
+	out.Flush()
+
+	return grpcOK, nil
+}
+
+// reads a single length prefixed grpc message.
+func readGRPCMessage(r io.Reader, gzipped bool) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated grpc message")
+		}
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(hdr[1:])
+	if length > maxGRPCMessage {
+		return nil, fmt.Errorf("grpc message of %d bytes is too large", length)
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, errors.New("truncated grpc message")
+	}
+
+	if hdr[0] == 1 {
+		if !gzipped {
+			return nil, errors.New("compressed grpc message without grpc-encoding")
+		}
+		gz, err := gzip.NewReader(bytes.NewReader(msg))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(io.LimitReader(gz, maxGRPCMessage))
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import "flag"
+
+var gutter = flag.Bool("gutter", false, "start each line with a block whose shade and color show its level")
+
+// the gutter block of each level, denser for the more severe levels so the
+// shape still shows without colors.
+var gutterBlocks = map[string]string{
+	"trace": " ",
+	"debug": " ",
+	"info":  "░",
+	"warn":  "▒",
+	"error": "▓",
+	"fatal": "█",
+	"panic": "█",
+}
+
+// appendGutter appends the gutter block of the level, lines that are not log
+// entries get a blank one so everything stays aligned.
+func appendGutter(b []byte, level string) []byte {
+	block, ok := gutterBlocks[level]
+	if !ok {
+		return append(b, "  "...)
+	}
+	b = append(b, getColor(level)...)
+	b = append(b, block...)
+	b = append(b, colorReset...)
+	return append(b, ' ')
+}
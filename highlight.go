@@ -0,0 +1,183 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	highlightIf    listFlag
+	highlightKeyIf listFlag
+)
+
+func init() {
+	flag.Var(&highlightIf, "highlight", "color the message and values of entries with a field matching key<op>value:color, like status>=500:red, may be repeated")
+	flag.Var(&highlightKeyIf, "highlight-key", "color only the value of a field matching key<op>value:color, like user_id=42:yellow, may be repeated")
+}
+
+// comparison compares a field to a value with an operator.  =, != and ~ (a
+// regular expression) compare the value as it is displayed, the others
+// compare numbers.
+type comparison struct {
+	key   string
+	op    string
+	value string
+	num   float64
+	re    *regexp.Regexp
+}
+
+// highlightRule colors an entry or one of its fields when the comparison
+// matches.
+type highlightRule struct {
+	comparison
+	color string
+}
+
+// the operators of a comparison, the longer ones first so >= is not taken
+// for >.
+var comparisonOps = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// the parsed -highlight and -highlight-key rules, in the order given.  the
+// first rule that matches picks the color.
+var (
+	lineHighlights []highlightRule
+	keyHighlights  []highlightRule
+)
+
+// setHighlights parses the -highlight and -highlight-key rules.
+func setHighlights() error {
+	lineHighlights, keyHighlights = nil, nil
+	for _, s := range highlightIf {
+		r, err := parseHighlight("highlight", s)
+		if err != nil {
+			return err
+		}
+		lineHighlights = append(lineHighlights, r)
+	}
+	for _, s := range highlightKeyIf {
+		r, err := parseHighlight("highlight-key", s)
+		if err != nil {
+			return err
+		}
+		keyHighlights = append(keyHighlights, r)
+	}
+	return nil
+}
+
+func parseHighlight(name, s string) (highlightRule, error) {
+	var r highlightRule
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return r, fmt.Errorf("-%s: %q is not key<op>value:color", name, s)
+	}
+	var err error
+	if r.comparison, err = parseComparison(name, s[:i]); err != nil {
+		return r, err
+	}
+	if r.color, err = colorCode(r.key, s[i+1:]); err != nil {
+		return r, fmt.Errorf("-%s: %w", name, err)
+	}
+	// the rules are still checked when colors are off.
+	if !colorsOn {
+		r.color = ""
+	}
+	return r, nil
+}
+
+// parseComparison parses key<op>value for the named flag.
+func parseComparison(name, s string) (comparison, error) {
+	var c comparison
+	i := strings.IndexAny(s, "!=<>~")
+	if i <= 0 {
+		return c, fmt.Errorf("-%s: %q is not key<op>value", name, s)
+	}
+	c.key = s[:i]
+	for _, op := range comparisonOps {
+		if v, ok := strings.CutPrefix(s[i:], op); ok {
+			c.op, c.value = op, v
+			break
+		}
+	}
+
+	var err error
+	switch c.op {
+	case "":
+		return c, fmt.Errorf("-%s: %q is not key<op>value", name, s)
+	case "~":
+		if c.re, err = regexp.Compile(c.value); err != nil {
+			return c, fmt.Errorf("-%s: %w", name, err)
+		}
+	case ">", ">=", "<", "<=":
+		var ok bool
+		if c.num, ok = parseNumber(c.value); !ok {
+			return c, fmt.Errorf("-%s: %q is not a number", name, c.value)
+		}
+	}
+	return c, nil
+}
+
+// String returns the comparison as it was given.
+func (c *comparison) String() string {
+	return c.key + c.op + c.value
+}
+
+// match returns true if the field is set and compares to the value.  a field
+// that is not a number never matches a numeric comparison.
+func (c *comparison) match(e *Entry) bool {
+	field := valueRule{key: c.key}
+	val, ok := field.value(e)
+	if !ok {
+		return false
+	}
+	return c.compare(val)
+}
+
+// compare returns true if val compares to the value.
+func (c *comparison) compare(val string) bool {
+	switch c.op {
+	case "=":
+		return val == c.value
+	case "!=":
+		return val != c.value
+	case "~":
+		return c.re.MatchString(val)
+	}
+	n, ok := parseNumber(val)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case ">":
+		return n > c.num
+	case ">=":
+		return n >= c.num
+	case "<":
+		return n < c.num
+	}
+	return n <= c.num
+}
+
+// lineHighlight returns the color of the first -highlight rule matching the
+// entry, or clr if none does.
+func lineHighlight(e *Entry, clr string) string {
+	for i := range lineHighlights {
+		if r := &lineHighlights[i]; r.color != "" && r.match(e) {
+			return r.color
+		}
+	}
+	return clr
+}
+
+// keyHighlight returns the color of the first -highlight-key rule of the key
+// matching the entry, or clr if none does.
+func keyHighlight(e *Entry, key, clr string) string {
+	for i := range keyHighlights {
+		if r := &keyHighlights[i]; r.key == key && r.color != "" && r.match(e) {
+			return r.color
+		}
+	}
+	return clr
+}
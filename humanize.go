@@ -0,0 +1,158 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationKeys = flag.String("duration-keys", "", "show the numbers in the given comma separated fields as durations, like 1.52s")
+	durationUnit = flag.Duration("duration-unit", time.Nanosecond, "the unit of the numbers in the -duration-keys fields")
+	bytesKeys    = flag.String("bytes-keys", "", "show the numbers in the given comma separated fields as sizes, like 1.0MiB")
+	humanizeRaw  = flag.Bool("humanize-raw", false, "also show the raw value of the -duration-keys and -bytes-keys fields")
+)
+
+// the kind of value of each humanized field, and the flags they were built
+// from so a reload of the config rebuilds them.
+var (
+	humanKinds          map[string]byte
+	humanDurs, humanSzs string
+)
+
+// humanKind returns 'd' for a -duration-keys field, 'b' for a -bytes-keys
+// field and 0 for any other field.
+func humanKind(key string) byte {
+	if *durationKeys == "" && *bytesKeys == "" {
+		return 0
+	}
+	if humanKinds == nil || humanDurs != *durationKeys || humanSzs != *bytesKeys {
+		humanDurs, humanSzs = *durationKeys, *bytesKeys
+		humanKinds = make(map[string]byte)
+		for _, k := range strings.Split(humanDurs, ",") {
+			humanKinds[k] = 'd'
+		}
+		for _, k := range strings.Split(humanSzs, ",") {
+			humanKinds[k] = 'b'
+		}
+		delete(humanKinds, "")
+	}
+	return humanKinds[key]
+}
+
+// appendHumanized appends the value of a -duration-keys or -bytes-keys field
+// in a readable form, values that are not numbers are appended as is.
+func appendHumanized(b []byte, kind byte, v any) []byte {
+	n, ok := v.(float64)
+	if !ok {
+		if s, isStr := v.(string); isStr {
+			n, ok = parseNumber(s)
+		}
+	}
+	if !ok {
+		return appendValue(b, v)
+	}
+
+	start := len(b)
+	if kind == 'd' {
+		b = appendDuration(b, n*float64(*durationUnit))
+	} else {
+		b = appendBytes(b, n)
+	}
+	if *humanizeRaw {
+		raw := appendValue(nil, v)
+		if string(raw) != string(b[start:]) {
+			b = append(b, dimColor...)
+			b = append(b, '(')
+			b = append(b, raw...)
+			b = append(b, ')')
+			b = append(b, colorReset...)
+		}
+	}
+	return b
+}
+
+// parseNumber parses a number written as a string, which some loggers do for
+// large integers.
+func parseNumber(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil && !math.IsInf(n, 0) && !math.IsNaN(n)
+}
+
+// the units of durations under a minute.
+var durationUnits = []struct {
+	name string
+	size float64
+}{
+	{"s", float64(time.Second)},
+	{"ms", float64(time.Millisecond)},
+	{"µs", float64(time.Microsecond)},
+	{"ns", 1},
+}
+
+// appendDuration appends a duration of ns nanoseconds with three significant
+// digits, like 1.52s or 340ms.  a minute or more is rounded to the second,
+// like 2m3s.
+func appendDuration(b []byte, ns float64) []byte {
+	if ns < 0 {
+		b = append(b, '-')
+		ns = -ns
+	}
+	if ns >= float64(time.Minute) {
+		return append(b, time.Duration(ns).Round(time.Second).String()...)
+	}
+	for _, u := range durationUnits {
+		// 999.6ns is shown as 1µs, not as 1000ns.
+		if ns/u.size >= 0.9995 || u.size == 1 {
+			b = appendSignificant(b, ns/u.size)
+			return append(b, u.name...)
+		}
+	}
+	return b
+}
+
+// the binary units of sizes.
+var byteUnits = []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// appendBytes appends a size with one decimal, like 1.0MiB, or in bytes if
+// it is under 1KiB.
+func appendBytes(b []byte, n float64) []byte {
+	if n < 0 {
+		b = append(b, '-')
+		n = -n
+	}
+	if n < 1024 {
+		b = strconv.AppendFloat(b, n, 'f', -1, 64)
+		return append(b, 'B')
+	}
+	unit := ""
+	for _, u := range byteUnits {
+		n /= 1024
+		unit = u
+		if n < 1024 {
+			break
+		}
+	}
+	b = strconv.AppendFloat(b, n, 'f', 1, 64)
+	return append(b, unit...)
+}
+
+// appendSignificant appends a number rounded to three significant digits,
+// without trailing zeros.
+func appendSignificant(b []byte, f float64) []byte {
+	digits := 2
+	switch {
+	case f >= 100:
+		digits = 0
+	case f >= 10:
+		digits = 1
+	}
+	s := strconv.FormatFloat(f, 'f', digits, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return append(b, s...)
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+var k8sMeta = flag.Bool("k8s", false, "add the namespace, pod and container of kubernetes container log files, and the pod labels when running in a cluster")
+
+// where a pod finds its service account, used to ask the api server for the
+// labels of a pod.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+var (
+	// /var/log/pods/<namespace>_<pod>_<uid>/<container>/0.log
+	podDirRe = regexp.MustCompile(`^([^_]+)_([^_]+)_[0-9a-f-]{36}$`)
+	// /var/log/containers/<pod>_<namespace>_<container>-<container id>.log
+	containerFileRe = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-[0-9a-f]{64}\.log$`)
+)
+
+// the kubernetes fields of each source, empty for files that are not
+// container logs.
+var k8sFields = make(map[string]map[string]string)
+
+// addK8sFields adds the kubernetes fields of the source of the entry.
+func addK8sFields(e *Entry) {
+	fields, ok := k8sFields[e.Source]
+	if !ok {
+		fields = podFields(e.Source)
+		k8sFields[e.Source] = fields
+	}
+	for k, v := range fields {
+		if _, ok := e.Fields[k]; !ok {
+			e.Fields[k] = v
+		}
+	}
+}
+
+// podFields returns the fields of a container log file, from its path and
+// the labels of its pod.
+func podFields(path string) map[string]string {
+	ns, pod, container, ok := parsePodPath(path)
+	if !ok {
+		return nil
+	}
+	fields := map[string]string{
+		"k8s.namespace": ns,
+		"k8s.pod":       pod,
+		"k8s.container": container,
+	}
+
+	labels, err := podLabels(ns, pod)
+	if err != nil {
+		printWarning(fmt.Sprintf("labels of pod %s/%s: %v", ns, pod, err))
+	}
+	for k, v := range labels {
+		fields["k8s.label."+k] = v
+	}
+	return fields
+}
+
+// parsePodPath returns the namespace, pod and container of the path of a
+// container log file of a node.
+func parsePodPath(path string) (ns, pod, container string, ok bool) {
+	path = filepath.ToSlash(path)
+	if m := containerFileRe.FindStringSubmatch(filepath.Base(path)); m != nil && strings.Contains(path, "/containers/") {
+		return m[2], m[1], m[3], true
+	}
+	parts := strings.Split(path, "/")
+	for i := len(parts) - 3; i >= 0; i-- {
+		if m := podDirRe.FindStringSubmatch(parts[i]); m != nil {
+			return m[1], m[2], parts[i+1], true
+		}
+	}
+	return "", "", "", false
+}
+
+// podLabels asks the api server for the labels of a pod.  nothing is
+// returned when glogv is not running in a cluster.
+func podLabels(ns, pod string) (map[string]string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if host == "" || port == "" || err != nil {
+		return nil, nil
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+
+	client := &http.Client{
+		Timeout:   2 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	url := fmt.Sprintf("https://%s/api/v1/namespaces/%s/pods/%s", net.JoinHostPort(host, port), ns, pod)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api server: %s", resp.Status)
+	}
+
+	var p struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	return p.Metadata.Labels, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// the glob patterns of -hide and -only, and whether each key seen so far is
+// shown.
+var (
+	hidePatterns []string
+	onlyPatterns []string
+	shownKeys    = make(map[string]bool)
+)
+
+// setKeyFilters parses the -hide and -only patterns.
+func setKeyFilters() error {
+	var err error
+	if hidePatterns, err = parsePatterns("hide", *hideKeys); err != nil {
+		return err
+	}
+	onlyPatterns, err = parsePatterns("only", *onlyKeys)
+	return err
+}
+
+func parsePatterns(name, list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	patterns := strings.Split(list, ",")
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("-%s: bad pattern %q", name, p)
+		}
+	}
+	return patterns, nil
+}
+
+// showKey returns true if the field is not filtered out by -hide or -only.
+func showKey(key string) bool {
+	if hidePatterns == nil && onlyPatterns == nil {
+		return true
+	}
+	if show, ok := shownKeys[key]; ok {
+		return show
+	}
+
+	show := onlyPatterns == nil || matchAny(onlyPatterns, key)
+	if show && matchAny(hidePatterns, key) {
+		show = false
+	}
+	shownKeys[key] = show
+	return show
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
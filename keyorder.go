@@ -0,0 +1,133 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+var (
+	firstKeys     = flag.String("first", "", "comma separated keys shown first in the given order, before the other keys")
+	preserveOrder = flag.Bool("preserve-order", false, "show the keys in the order of the line instead of sorted")
+)
+
+// the position of each key in the line, reused for every entry.
+var keyPos = make(map[string]int)
+
+// orderKeys reorders the sorted keys of the entry for -preserve-order and
+// -first.  keys the line does not have, like those added by -add-field, stay
+// sorted after the others.
+func orderKeys(keys []string, e *Entry) {
+	if *preserveOrder {
+		clear(keyPos)
+		lineKeyOrder(e.Raw, keyPos)
+		sort.SliceStable(keys, func(i, j int) bool {
+			return rank(keys[i]) < rank(keys[j])
+		})
+	}
+	if *firstKeys == "" {
+		return
+	}
+
+	// move the -first keys to the front, the rest keep their order.
+	n := 0
+	for _, k := range strings.Split(*firstKeys, ",") {
+		for i := n; i < len(keys); i++ {
+			if keys[i] == k {
+				copy(keys[n+1:i+1], keys[n:i])
+				keys[n] = k
+				n++
+				break
+			}
+		}
+	}
+}
+
+// rank returns the position of the key in the line, keys that are not in it
+// come last.
+func rank(k string) int {
+	if pos, ok := keyPos[k]; ok {
+		return pos
+	}
+	return len(keyPos)
+}
+
+// lineKeyOrder records the position of each key of a json or logfmt line.
+// the keys of nested objects are recorded as dotted keys, like they are
+// flattened.
+func lineKeyOrder(b []byte, pos map[string]int) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' {
+		scanLogfmt(b, func(k string, _ string, _ bool) {
+			addKeyPos(pos, k)
+		})
+		return
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return
+	}
+	objectKeyOrder(dec, "", pos)
+}
+
+// objectKeyOrder records the keys of the object being decoded, up to and
+// including its closing brace.
+func objectKeyOrder(dec *json.Decoder, prefix string, pos map[string]int) bool {
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return false
+		}
+		key = prefix + key
+		addKeyPos(pos, key)
+
+		tok, err = dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'):
+			if !objectKeyOrder(dec, key+".", pos) {
+				return false
+			}
+		case json.Delim('['):
+			if !skipArray(dec) {
+				return false
+			}
+		}
+	}
+	_, err := dec.Token()
+	return err == nil
+}
+
+// skipArray skips the rest of the array being decoded.
+func skipArray(dec *json.Decoder) bool {
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return true
+}
+
+func addKeyPos(pos map[string]int, k string) {
+	if _, ok := pos[k]; !ok {
+		pos[k] = len(pos)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"strings"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// fieldKeys are the keys of the standard logging fields.  each field has a
+// list of alternatives and the first one found in a line is used.
+type fieldKeys = format.Keys
+
+var (
+	// keys used by zerolog, and by the text formats which name the fields
+	// themselves.
+	stdKeys = format.StdKeys
+
+	// keys of logfmt lines, with the short names logfmt loggers commonly use.
+	logfmtKeys = fieldKeys{
+		Time:    []string{"time", "ts", "t"},
+		Level:   []string{"level", "lvl"},
+		Message: []string{"msg", "message"},
+		Error:   []string{"error", "err"},
+	}
+)
+
+// keys of json lines written by other structured loggers, selected with the
+// -preset option.
+var presets = format.Presets
+
+// replaces the keys the options are set for with the comma separated keys.
+func setKeys(k *fieldKeys, tm, lvl, msg, errKey string) {
+	for _, opt := range []struct {
+		val  string
+		keys *[]string
+	}{
+		{tm, &k.Time},
+		{lvl, &k.Level},
+		{msg, &k.Message},
+		{errKey, &k.Error},
+	} {
+		if opt.val != "" {
+			*opt.keys = strings.Split(opt.val, ",")
+		}
+	}
+}
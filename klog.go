@@ -0,0 +1,49 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// klog header: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+var klogLine = regexp.MustCompile(`^([IWEF])(\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})\.(\d+)\s+(\d+) ([^ \]]+:\d+)\] ?(.*)$`)
+
+// klog severity letters.
+var klogLevels = map[byte]string{
+	'I': "info",
+	'W': "warn",
+	'E': "error",
+	'F': "fatal",
+}
+
+// returns true if the line looks like klog (kubernetes components).
+func isKlog(b []byte) bool {
+	return klogLine.Match(b)
+}
+
+// parseKlog parses a klog line.  klog does not log the year, so the current
+// year is assumed.
+func parseKlog(e *Entry, b []byte) error {
+	m := klogLine.FindSubmatch(b)
+	if m == nil {
+		return errNoMatch
+	}
+
+	clear(e.Fields)
+	e.Fields["level"] = klogLevels[m[1][0]]
+	e.Fields["message"] = string(m[10])
+	e.Fields["thread"] = string(m[8])
+	e.Fields["caller"] = string(m[9])
+	extract(e, &stdKeys)
+
+	var n [6]int
+	for i := range n {
+		n[i], _ = strconv.Atoi(string(m[i+2]))
+	}
+	frac, _ := strconv.Atoi((string(m[7]) + "000000000")[:9])
+	e.Time = time.Date(time.Now().Year(), time.Month(n[0]), n[1], n[2], n[3], n[4], frac, time.Local)
+
+	return nil
+}
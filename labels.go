@@ -0,0 +1,106 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// labelFlags are the -label file=name aliases of the sources.
+var labelFlags listFlag
+
+func init() {
+	flag.Var(&labelFlags, "label", "prefix the lines of a file with the given label, like file=api, may be repeated")
+}
+
+// the colored and padded prefix of the lines of each source, empty when
+// there is only one source and no -label, and the width they are padded to.
+var (
+	sourceLabels map[string]string
+	labelWidth   int
+)
+
+// setLabels picks the label of each source, which is its -label alias or the
+// name of the file.  the full path is used if two files have the same name.
+// srcs are the labels of the sources, more is set if files may be added
+// later by addLabel so even a single file is labeled.
+func setLabels(srcs []string, more bool) error {
+	labelAliases = make(map[string]string)
+	aliases := make(map[string]string)
+	for _, s := range labelFlags {
+		file, name, ok := strings.Cut(s, "=")
+		if !ok || file == "" || name == "" {
+			return fmt.Errorf("-label: %q is not file=label", s)
+		}
+		aliases[file] = name
+		labelAliases[file] = name
+	}
+	if len(srcs) < 2 && len(aliases) == 0 && !more {
+		return nil
+	}
+
+	bases := make(map[string]int)
+	for _, src := range srcs {
+		bases[filepath.Base(src)]++
+	}
+
+	names := make([]string, len(srcs))
+	width := 0
+	for i, label := range srcs {
+		base := filepath.Base(label)
+		name, ok := aliases[label]
+		if ok {
+			delete(aliases, label)
+		} else if name, ok = aliases[base]; ok {
+			delete(aliases, base)
+		} else if bases[base] > 1 {
+			name = label
+		} else {
+			name = base
+		}
+		names[i] = name
+		width = max(width, len(name))
+	}
+	for file := range aliases {
+		if !more {
+			return fmt.Errorf("-label: %q is not one of the files", file)
+		}
+	}
+
+	sourceLabels = make(map[string]string)
+	labelWidth = width
+	for i, src := range srcs {
+		clr := markColors[i%len(markColors)]
+		sourceLabels[src] = fmt.Sprintf("%s%-*s%s ", clr, width, names[i], colorReset)
+	}
+	return nil
+}
+
+// labelAliases are the -label aliases by file, for the files of addLabel.
+var labelAliases map[string]string
+
+// addLabel labels a file found while following a -tail glob with its alias or
+// name and the next color, if it has no label yet.
+func addLabel(src string) {
+	if _, ok := sourceLabels[src]; ok {
+		return
+	}
+	if sourceLabels == nil {
+		sourceLabels = make(map[string]string)
+	}
+	name, ok := labelAliases[src]
+	if !ok {
+		if name, ok = labelAliases[filepath.Base(src)]; !ok {
+			name = filepath.Base(src)
+		}
+	}
+	clr := markColors[len(sourceLabels)%len(markColors)]
+	sourceLabels[src] = fmt.Sprintf("%s%-*s%s ", clr, labelWidth, name, colorReset)
+}
+
+// appendLabel appends the prefix of the lines of the source.
+func appendLabel(b []byte, src string) []byte {
+	return append(b, sourceLabels[src]...)
+}
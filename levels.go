@@ -0,0 +1,34 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// numericLevels are the levels of bunyan and pino, sorted by number.
+var numericLevels = format.DefaultNumericLevels
+
+// setNumericLevels adds to or replaces the numeric levels with a comma
+// separated list of number=name pairs.
+func setNumericLevels(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		num, name, ok := strings.Cut(pair, "=")
+		n, err := strconv.ParseFloat(num, 64)
+		if !ok || err != nil {
+			return fmt.Errorf("-level-numbers: %q is not number=level", pair)
+		}
+		name = format.NormalizeLevel(name)
+		if !format.IsLevel(name) {
+			return fmt.Errorf("-level-numbers: unknown level %q", name)
+		}
+		numericLevels = numericLevels.With(n, name)
+	}
+	return nil
+}
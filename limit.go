@@ -0,0 +1,239 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	headLines = flag.Int("head", 0, "only show the first n lines of each file")
+	lastLimit lastFlag
+)
+
+func init() {
+	flag.Var(&lastLimit, "last", "only show the last n lines of each file, or a duration like 1h up to its last entry, uncompressed files are read from the end")
+}
+
+// lastFlag is the value of -last, a number of lines or a duration.
+type lastFlag struct {
+	lines  int
+	window time.Duration
+}
+
+func (l *lastFlag) String() string {
+	if l.window > 0 {
+		return l.window.String()
+	}
+	return strconv.Itoa(l.lines)
+}
+
+func (l *lastFlag) Set(s string) error {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		l.lines, l.window = n, 0
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("%q is not a number of lines or a duration", s)
+	}
+	l.lines, l.window = 0, d
+	return nil
+}
+
+// isSet returns true if -last was given a number of lines or a duration.
+func (l *lastFlag) isSet() bool {
+	return l.lines > 0 || l.window > 0
+}
+
+// catHead shows the first n lines of a source.
+func catHead(s Source, n int) error {
+	if err := s.Open(); err != nil {
+		return err
+	}
+	defer s.Close()
+
+	src := s.Label()
+	for shown := 0; shown < n; {
+		l, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if l.notice == "" && l.dropped == 0 {
+			shown++
+		}
+		show(src, l)
+		if err := pagerError(); err != nil {
+			return err
+		}
+	}
+	flushCRI(src)
+	flushDoc(src, reformatLine)
+	return nil
+}
+
+// timedLine is a line kept by -last with the time of its entry, or of the
+// entry before it if it has none.
+type timedLine struct {
+	data []byte
+	time time.Time
+}
+
+// catLast shows the end of a source given by -last.  a file that is not
+// compressed is read from where it starts, anything else is read to the end
+// keeping the lines that may be shown.
+func catLast(s Source, last lastFlag) error {
+	if f, ok := s.(*fileSource); ok {
+		f.last = last
+	}
+	if err := s.Open(); err != nil {
+		return err
+	}
+	defer s.Close()
+
+	src := s.Label()
+	if f, ok := s.(*fileSource); ok && f.seeked {
+		// the lines before the seek are not counted, so their numbers are
+		// left out.
+		unnumbered[src] = true
+		for {
+			l, err := s.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			show(src, l)
+			if err := pagerError(); err != nil {
+				return err
+			}
+		}
+		flushCRI(src)
+		flushDoc(src, reformatLine)
+		return nil
+	}
+
+	var kept []timedLine
+	var latest time.Time
+	next, total := 0, 0
+	for {
+		l, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if l.notice != "" || l.dropped > 0 {
+			continue
+		}
+		total++
+		// keep the last lines in a ring.
+		if last.window == 0 {
+			if len(kept) < last.lines {
+				kept = append(kept, timedLine{data: bytes.Clone(l.data)})
+			} else {
+				kept[next].data = append(kept[next].data[:0], l.data...)
+				next = (next + 1) % last.lines
+			}
+			continue
+		}
+
+		// drop the lines that are more than the window before the latest.
+		if t := lineTime(src, l.data); !t.IsZero() {
+			latest = t
+		}
+		kept = append(kept, timedLine{data: bytes.Clone(l.data), time: latest})
+		drop := 0
+		for drop < len(kept) && kept[drop].time.Before(latest.Add(-last.window)) {
+			drop++
+		}
+		kept = kept[drop:]
+	}
+
+	// keep the line numbers of the last lines correct.
+	lineNos[src] = total - len(kept)
+	for i := range kept {
+		show(src, logLine{data: kept[(next+i)%len(kept)].data})
+		if err := pagerError(); err != nil {
+			return err
+		}
+	}
+	flushCRI(src)
+	flushDoc(src, reformatLine)
+	return nil
+}
+
+// seekLast moves a regular file to the start of what -last shows and returns
+// false for anything that can not be seeked, like a pipe.
+func seekLast(file *os.File, src string, last lastFlag) (bool, error) {
+	fi, err := file.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return false, err
+	}
+	var off int64
+	if last.window > 0 {
+		off, err = windowOffset(file, fi.Size(), src, last.window)
+	} else {
+		off, err = lastLinesOffset(file, fi.Size(), last.lines)
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := file.Seek(off, io.SeekStart); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// windowOffset returns the offset of the first line within the window before
+// the last entry of a file.  the lines are read backwards from the end of the
+// file until an entry before the window is found.
+func windowOffset(file *os.File, size int64, src string, window time.Duration) (int64, error) {
+	const chunk = 64 * 1024
+	buf := make([]byte, chunk)
+
+	var data []byte // the lines read so far that are not yet looked at.
+	var cutoff time.Time
+	off := size
+	for off > 0 {
+		length := min(int64(chunk), off)
+		off -= length
+		if _, err := file.ReadAt(buf[:length], off); err != nil && err != io.EOF {
+			return 0, err
+		}
+		data = append(buf[:length:length], data...)
+
+		// look at each complete line from the last one, the first line is
+		// only complete at the start of the file.
+		end := len(data)
+		for {
+			i := bytes.LastIndexByte(data[:end], '\n')
+			if i < 0 && off > 0 {
+				break
+			}
+			if t := lineTime(src, trimNewline(data[i+1:end])); !t.IsZero() {
+				if cutoff.IsZero() {
+					cutoff = t.Add(-window)
+				} else if t.Before(cutoff) {
+					return min(off+int64(end)+1, size), nil
+				}
+			}
+			if i < 0 {
+				break
+			}
+			end = i
+		}
+		data = bytes.Clone(data[:end])
+	}
+	return 0, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeFlag is a number of bytes that may be given with a K, M or G suffix.
+type sizeFlag int
+
+// String returns the size in the largest unit it is a multiple of, so it can
+// be set again.
+func (s *sizeFlag) String() string {
+	n := int(*s)
+	for _, unit := range []string{"", "K", "M"} {
+		if n == 0 || n%1024 != 0 {
+			return strconv.Itoa(n) + unit
+		}
+		n /= 1024
+	}
+	return strconv.Itoa(n) + "G"
+}
+
+func (s *sizeFlag) Set(v string) error {
+	mult := 1
+	switch {
+	case strings.HasSuffix(strings.ToUpper(v), "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(strings.ToUpper(v), "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(strings.ToUpper(v), "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > (1<<31-1)/mult {
+		return fmt.Errorf("%q is not a size like 512K or 64M", v)
+	}
+	*s = sizeFlag(n * mult)
+	return nil
+}
+
+// the longest line that is read, cloudtrail exports and concatenated objects
+// are often written without any newlines.  longer lines are skipped with a
+// notice.
+var maxLineSize = sizeFlag(64 << 20)
+
+func init() {
+	flag.Var(&maxLineSize, "max-line-size", "skip lines longer than the given size, like 512K or 64M")
+}
+
+// longLine returns the notice shown in place of a line of n bytes that is
+// longer than -max-line-size.
+func longLine(n int64) logLine {
+	return logLine{notice: fmt.Sprintf("skipped a line of %s, it is longer than -max-line-size %s", formatBytes(n), formatBytes(int64(maxLineSize)))}
+}
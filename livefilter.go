@@ -0,0 +1,164 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+var filterCommands = flag.Bool("commands", false, "read filter commands like :grep timeout from the terminal while using -tail")
+
+// the flags of the filters that can be changed while following.
+var liveFilters = []string{
+	"grep", "grep-key", "invert", "include-if", "exclude-if",
+	"hide", "only", "since", "until", "highlight", "highlight-key", "where",
+}
+
+const filterUsage = "usage: :<filter> value, :<filter> to clear, :-<filter> value to remove, :clear or :filters"
+
+// readCommands sends the lines typed on the terminal that start with a colon
+// to the channel, without the colon.
+func readCommands() (<-chan string, error) {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, fmt.Errorf("-commands: %w", err)
+	}
+	cmds := make(chan string)
+	go func() {
+		defer tty.Close()
+		sc := bufio.NewScanner(tty)
+		for sc.Scan() {
+			if s, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), ":"); ok {
+				cmds <- s
+			}
+		}
+	}()
+	return cmds, nil
+}
+
+// runCommand changes a filter while following and shows the filters in
+// effect, the filters are left as they were if the command has an error.
+//
+//	grep timeout          only show entries matching timeout
+//	exclude-if path=/health*
+//	-exclude-if path=/health*
+//	grep                  stop grepping
+//	clear                 go back to the filters glogv was started with
+func runCommand(cmd string) {
+	if err := applyCommand(cmd); err != nil {
+		printWarning(err.Error())
+		return
+	}
+	printNotice(activeFilters())
+}
+
+// the values of the filters when following started, which :clear goes back
+// to.
+var startFilters map[string]string
+
+func applyCommand(cmd string) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(cmd), " ")
+	arg = strings.TrimSpace(arg)
+	remove := false
+	if n, ok := strings.CutPrefix(name, "-"); ok {
+		name, remove = n, true
+	}
+
+	if startFilters == nil {
+		startFilters = filterValues()
+	}
+	switch {
+	case name == "filters" && arg == "":
+		return nil
+	case name == "clear" && arg == "":
+		return setFilterValues(startFilters)
+	case !slices.Contains(liveFilters, name):
+		return errors.New(filterUsage)
+	}
+
+	values := filterValues()
+	f := flag.Lookup(name)
+	_, isList := f.Value.(*listFlag)
+	switch {
+	case remove:
+		if !isList || arg == "" {
+			return fmt.Errorf(":-%s: only a value of a list can be removed", name)
+		}
+		list := strings.Split(values[name], ",")
+		i := slices.Index(list, arg)
+		if i < 0 {
+			return fmt.Errorf(":-%s: %q is not set", name, arg)
+		}
+		values[name] = strings.Join(slices.Delete(list, i, i+1), ",")
+	case name == "invert" && arg == "":
+		values[name] = fmt.Sprint(!*invert)
+	case isList && arg != "" && values[name] != "":
+		values[name] += "," + arg
+	case arg == "":
+		values[name] = f.DefValue
+	default:
+		values[name] = arg
+	}
+	return setFilterValues(values)
+}
+
+// filterValues returns the values of the live filter flags.
+func filterValues() map[string]string {
+	values := make(map[string]string, len(liveFilters))
+	for _, name := range liveFilters {
+		values[name] = flag.Lookup(name).Value.String()
+	}
+	return values
+}
+
+// setFilterValues sets the live filter flags and applies them, putting them
+// back if they have an error.
+func setFilterValues(values map[string]string) error {
+	saved, savedValues := saveFilters(), filterValues()
+	err := setFlagValues(values)
+	if err == nil {
+		err = applyFilters()
+	}
+	if err != nil {
+		setFlagValues(savedValues)
+		saved.restore()
+	}
+	return err
+}
+
+func setFlagValues(values map[string]string) error {
+	for name, val := range values {
+		f := flag.Lookup(name)
+		if l, ok := f.Value.(*listFlag); ok {
+			*l = nil
+			if val == "" {
+				continue
+			}
+		}
+		if err := f.Value.Set(val); err != nil {
+			return fmt.Errorf("-%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// activeFilters describes the live filters that are set.
+func activeFilters() string {
+	var sb strings.Builder
+	sb.WriteString("filters:")
+	for _, name := range liveFilters {
+		f := flag.Lookup(name)
+		if val := f.Value.String(); val != f.DefValue {
+			fmt.Fprintf(&sb, " -%s %s", name, val)
+		}
+	}
+	if sb.Len() == len("filters:") {
+		sb.WriteString(" none")
+	}
+	return sb.String()
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// returns true if the line looks like logfmt, at least two key=value pairs.
+func isLogfmt(b []byte) bool {
+	pairs := 0
+	ok := scanLogfmt(b, func(_ string, _ string, hasValue bool) {
+		if hasValue {
+			pairs++
+		}
+	})
+	return ok && pairs >= 2
+}
+
+// returns true if the line is logfmt with a level or message, so text that
+// happens to contain a couple of key=value pairs is not taken for an entry.
+func isLogfmtEntry(b []byte, keys *fieldKeys) bool {
+	pairs, named := 0, false
+	ok := scanLogfmt(b, func(k string, _ string, hasValue bool) {
+		if !hasValue {
+			return
+		}
+		pairs++
+		for _, key := range append(keys.Level, keys.Message...) {
+			if k == key {
+				named = true
+			}
+		}
+	})
+	return ok && pairs >= 2 && named
+}
+
+// parseLogfmt parses a logfmt line like `ts=... level=info msg="a b" k=v`.
+func parseLogfmt(e *Entry, b []byte, cfg *sourceConfig) error {
+	clear(e.Fields)
+	ok := scanLogfmt(b, func(k string, v string, hasValue bool) {
+		if hasValue {
+			e.Fields[k] = v
+		} else {
+			e.Fields[k] = true
+		}
+	})
+	if !ok {
+		return errNoMatch
+	}
+
+	extract(e, &cfg.logfmt)
+	return nil
+}
+
+// scanLogfmt calls fn for each key=value pair of a logfmt line.  a key
+// without a value is a flag.  false is returned if the line is not logfmt.
+func scanLogfmt(b []byte, fn func(k string, v string, hasValue bool)) bool {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] == '=' || b[0] == '"' {
+		return false
+	}
+
+	for len(b) > 0 {
+		// read the key.
+		i := bytes.IndexAny(b, "= ")
+		if i == 0 {
+			return false
+		}
+		if i < 0 {
+			fn(string(b), "", false)
+			return true
+		}
+		key := string(b[:i])
+		if b[i] == ' ' {
+			fn(key, "", false)
+			b = bytes.TrimLeft(b[i:], " ")
+			continue
+		}
+		b = b[i+1:]
+
+		// read the value, which may be quoted.
+		var val string
+		if len(b) > 0 && b[0] == '"' {
+			end := quotedEnd(b)
+			if end < 0 {
+				return false
+			}
+			s, err := strconv.Unquote(string(b[:end]))
+			if err != nil {
+				return false
+			}
+			val = s
+			b = b[end:]
+		} else {
+			end := bytes.IndexByte(b, ' ')
+			if end < 0 {
+				end = len(b)
+			}
+			val = string(b[:end])
+			b = b[end:]
+		}
+		fn(key, val, true)
+
+		if len(b) > 0 && b[0] != ' ' {
+			return false
+		}
+		b = bytes.TrimLeft(b, " ")
+	}
+
+	return true
+}
+
+// returns the index just past the closing quote of the quoted string at
+// the start of b, or -1 if it is not terminated.
+func quotedEnd(b []byte) int {
+	for i := 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"flag"
+	"io"
+	"time"
+)
+
+var mergeOn = flag.Bool("merge", false, "merge the lines of the files into one stream ordered by time")
+
+// mergeHead is the next line of a source being merged.
+type mergeHead struct {
+	src   Source
+	index int // the order of the source, which breaks ties.
+	line  logLine
+	time  time.Time
+}
+
+// mergeHeap orders the next lines of the sources by time.
+type mergeHeap []*mergeHead
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].index < h[j].index
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeHead)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// merge reads all of the sources and displays their lines ordered by time.
+// a line without a time keeps the time of the line before it, so it stays
+// with the entry it belongs to.  each source is expected to be in order
+// already, so only the next line of each is held at a time.
+func merge(srcs []Source) error {
+	h := make(mergeHeap, 0, len(srcs))
+	defer func() {
+		for _, s := range srcs {
+			s.Close()
+		}
+	}()
+	for i, s := range srcs {
+		if err := s.Open(); err != nil {
+			return err
+		}
+		head := &mergeHead{src: s, index: i}
+		ok, err := head.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, head)
+		}
+	}
+	heap.Init(&h)
+
+	for len(h) > 0 {
+		head := h[0]
+		show(head.src.Label(), head.line)
+		if err := pagerError(); err != nil {
+			return err
+		}
+		ok, err := head.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+			continue
+		}
+		heap.Pop(&h)
+		flushCRI(head.src.Label())
+		flushDoc(head.src.Label(), reformatLine)
+	}
+	return nil
+}
+
+// next reads the next line of the source, false is returned at the end.
+func (m *mergeHead) next() (bool, error) {
+	l, err := m.src.Next()
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.data = bytes.Clone(l.data)
+	m.line = l
+
+	t := criLineTime(l.data)
+	if t.IsZero() && l.notice == "" {
+		t = lineTime(m.src.Label(), l.data)
+	}
+	if !t.IsZero() {
+		m.time = t
+	}
+	return true, nil
+}
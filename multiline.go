@@ -0,0 +1,93 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+// maxDocLines is the number of lines a json document spread over several
+// lines may have before it is given up on and displayed as is.
+const maxDocLines = 10000
+
+// jsonDoc is a json document being assembled from the lines of a source.
+type jsonDoc struct {
+	buf   []byte
+	lines int
+	depth int
+	inStr bool
+	esc   bool
+}
+
+// the documents being assembled for each source with -multiline.
+var docs = make(map[string]*jsonDoc)
+
+// joinLines collects the lines of pretty printed json documents and passes
+// each complete document to fn.  lines outside of a document are passed to
+// fn as they are.
+func joinLines(src string, b []byte, fn func(string, []byte)) {
+	d, ok := docs[src]
+	if !ok {
+		d = &jsonDoc{}
+		docs[src] = d
+	}
+
+	// an object starting at the beginning of a line is a new document, so a
+	// pending one was never finished.
+	if d.lines > 0 && len(b) > 0 && b[0] == '{' {
+		flushDoc(src, fn)
+	}
+
+	if d.lines == 0 {
+		if len(b) == 0 || b[0] != '{' {
+			fn(src, b)
+			return
+		}
+	} else {
+		d.buf = append(d.buf, '\n')
+	}
+
+	d.buf = append(d.buf, b...)
+	d.lines++
+	d.scan(b)
+
+	if d.depth <= 0 || d.lines >= maxDocLines {
+		flushDoc(src, fn)
+	}
+}
+
+// flushDoc passes whatever has been collected for src to fn.
+func flushDoc(src string, fn func(string, []byte)) {
+	d, ok := docs[src]
+	if !ok || d.lines == 0 {
+		return
+	}
+	fn(src, d.buf)
+	d.buf = d.buf[:0]
+	d.lines, d.depth, d.inStr, d.esc = 0, 0, false, false
+}
+
+// scan keeps track of how deeply nested the document is after b.
+func (d *jsonDoc) scan(b []byte) {
+	for _, c := range b {
+		switch {
+		case d.esc:
+			d.esc = false
+		case d.inStr:
+			switch c {
+			case '\\':
+				d.esc = true
+			case '"':
+				d.inStr = false
+			}
+		case c == '"':
+			d.inStr = true
+		case c == '{' || c == '[':
+			d.depth++
+		case c == '}' || c == ']':
+			d.depth--
+		}
+	}
+}
+
+// flushDocs displays the unfinished documents of every source.
+func flushDocs() {
+	for src := range docs {
+		flushDoc(src, reformatLine)
+	}
+}
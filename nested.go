@@ -0,0 +1,67 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"sort"
+)
+
+// expandIndent is the indentation of each level of an -expand block.
+const expandIndent = "    "
+
+// isNested returns true if a field is shown in the -expand block instead of
+// the key/value section.
+func isNested(v any) bool {
+	m, ok := v.(map[string]any)
+	return ok && len(m) > 0 && *expand
+}
+
+// appendExpanded appends the nested objects of the entry as an indented
+// block on the lines after the entry.
+func appendExpanded(b []byte, e *Entry) []byte {
+	keys = keys[:0]
+	for k, v := range e.Fields {
+		if isNested(v) && showKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return b
+	}
+	sort.Strings(keys)
+
+	// the keys are reused while appending, so work from a copy.
+	nested := append([]string(nil), keys...)
+	clr := getColor(e.Level)
+	for _, k := range nested {
+		b = appendObject(b, k, e.Fields[k], clr, 1)
+	}
+	return b
+}
+
+func appendObject(b []byte, key string, v any, clr string, depth int) []byte {
+	b = append(b, colorReset...)
+	b = append(b, '\n')
+	for i := 0; i < depth; i++ {
+		b = append(b, expandIndent...)
+	}
+	b = append(b, tagColor...)
+	b = append(b, key...)
+	b = append(b, ':')
+
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		b = append(b, ' ')
+		b = append(b, clr...)
+		return appendValue(b, v)
+	}
+
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		b = appendObject(b, k, m[k], clr, depth+1)
+	}
+	return b
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sourceConfig holds the options that can be set for each source.
+type sourceConfig struct {
+	format  string    // input format, or auto to detect it.
+	json    fieldKeys // keys of the standard fields of json lines.
+	logfmt  fieldKeys // keys of the standard fields of logfmt lines.
+	records fieldKeys // keys of the objects in a {"Records":[...]} wrapper.
+	process processFields
+}
+
+// the options used by sources that did not override any, and the options of
+// those that did by label along with what they were given.
+var (
+	baseConfig    = sourceConfig{format: "auto", json: stdKeys, logfmt: logfmtKeys, records: presets["cloudtrail"]}
+	defaultConfig = baseConfig
+	sourceConfigs = make(map[string]*sourceConfig)
+	sourceOpts    = make(map[string]map[string]string)
+)
+
+// the options that can be given after a source to apply to just that source.
+var sourceOptions = map[string]bool{
+	"format":    true,
+	"preset":    true,
+	"time-key":  true,
+	"level-key": true,
+	"msg-key":   true,
+	"error-key": true,
+}
+
+// setDefaultConfig sets the default source options from the command line.
+// the preset is left out unless it was chosen so the records of a cloudtrail
+// export keep their own keys.
+func setDefaultConfig() error {
+	opts := map[string]string{
+		"format":    *inputFmt,
+		"time-key":  *timeKey,
+		"level-key": *levelKey,
+		"msg-key":   *msgKey,
+		"error-key": *errorKey,
+	}
+	if flagSet("preset") {
+		opts["preset"] = *preset
+	}
+	return applyOptions(&defaultConfig, opts)
+}
+
+// applyOptions sets the source options, the preset is applied before the
+// keys so they can override it.
+func applyOptions(cfg *sourceConfig, opts map[string]string) error {
+	if format, ok := opts["format"]; ok {
+		if err := checkFormat(format); err != nil {
+			return err
+		}
+		cfg.format = format
+	}
+
+	if name, ok := opts["preset"]; ok {
+		keys, ok := presets[name]
+		if !ok {
+			return fmt.Errorf("unknown -preset %q", name)
+		}
+		cfg.json = keys
+		cfg.process = presetFields[name]
+	}
+
+	setKeys(&cfg.json, opts["time-key"], opts["level-key"], opts["msg-key"], opts["error-key"])
+	setKeys(&cfg.logfmt, opts["time-key"], opts["level-key"], opts["msg-key"], opts["error-key"])
+
+	// keys that were chosen also apply to wrapped records.
+	for _, name := range []string{"preset", "time-key", "level-key", "msg-key", "error-key"} {
+		if opts[name] != "" {
+			cfg.records = cfg.json
+			break
+		}
+	}
+
+	return nil
+}
+
+// resetSourceConfigs sets the source options again from the flags, after the
+// config file was reloaded.  the options of a single source are applied on
+// top of the new defaults.
+func resetSourceConfigs() error {
+	defaultConfig = baseConfig
+	if err := setDefaultConfig(); err != nil {
+		return err
+	}
+	for label, opts := range sourceOpts {
+		cfg := defaultConfig
+		if err := applyOptions(&cfg, opts); err != nil {
+			return err
+		}
+		*sourceConfigs[label] = cfg
+	}
+	return nil
+}
+
+// returns the options of the source.
+func configOf(src string) *sourceConfig {
+	if cfg, ok := sourceConfigs[src]; ok {
+		return cfg
+	}
+	return &defaultConfig
+}
+
+// parseSources creates the sources from the command line arguments.  options
+// like -format and -preset that follow a source apply to just that source,
+// for example: glogv api.log -preset zap nginx.log -format clf
+func parseSources(args []string) ([]Source, error) {
+	var srcs []Source
+	var opts map[string]string
+
+	// saves the options of the previous source.
+	done := func() error {
+		if len(opts) == 0 {
+			return nil
+		}
+		cfg := defaultConfig
+		if err := applyOptions(&cfg, opts); err != nil {
+			return err
+		}
+		label := srcs[len(srcs)-1].Label()
+		sourceConfigs[label] = &cfg
+		sourceOpts[label] = opts
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(srcs) == 0 || arg == "-" || !strings.HasPrefix(arg, "-") {
+			if err := done(); err != nil {
+				return nil, err
+			}
+			srcs = append(srcs, newSource(arg))
+			opts = make(map[string]string)
+			continue
+		}
+
+		name, val, hasVal := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !sourceOptions[name] {
+			return nil, fmt.Errorf("-%s can not be set for a single source", name)
+		}
+		if !hasVal {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("-%s needs a value", name)
+			}
+			i++
+			val = args[i]
+		}
+		opts[name] = val
+	}
+
+	if err := done(); err != nil {
+		return nil, err
+	}
+	return srcs, nil
+}
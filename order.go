@@ -0,0 +1,38 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// timestamp and source of the previous log entry.
+var (
+	lastTime time.Time
+	lastSrc  string
+)
+
+// checkTimeOrder compares the timestamp of a log entry with the previous
+// entry and returns a warning if it went backwards by more than the
+// -order-tolerance, otherwise an empty string is returned.
+func checkTimeOrder(src string, tm time.Time) string {
+	if tm.IsZero() {
+		return ""
+	}
+
+	prev, prevSrc := lastTime, lastSrc
+	lastTime, lastSrc = tm, src
+	if prev.IsZero() {
+		return ""
+	}
+
+	diff := prev.Sub(tm)
+	if diff <= *orderSlack {
+		return ""
+	}
+
+	if prevSrc != src {
+		return fmt.Sprintf("%s: time went backwards %v from previous entry in %s", src, diff, prevSrc)
+	}
+	return fmt.Sprintf("%s: time went backwards %v", src, diff)
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"encoding/hex"
+	"math"
+	"time"
+)
+
+// otlpLevels maps OTLP severity number ranges to levels, each level covers
+// four severity numbers starting at 1.
+var otlpLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// decodeOTLPLogs decodes an OTLP ExportLogsServiceRequest into one key/value
+// map per log record, using the same field names as zerolog.
+func decodeOTLPLogs(b []byte) ([]map[string]any, error) {
+	req, err := parseProtobuf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]any
+	for _, rl := range req {
+		if rl.num != 1 || rl.wire != wireBytes {
+			continue
+		}
+		resourceLogs, err := parseProtobuf(rl.bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		// attributes of the resource, like service.name, are added to every
+		// record it produced.
+		resource := make(map[string]any)
+		for _, f := range resourceLogs {
+			if f.num == 1 && f.wire == wireBytes {
+				if err := decodeOTLPResource(f.bytes, resource); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for _, f := range resourceLogs {
+			if f.num != 2 || f.wire != wireBytes {
+				continue
+			}
+			scopeLogs, err := parseProtobuf(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, sl := range scopeLogs {
+				if sl.num != 2 || sl.wire != wireBytes {
+					continue
+				}
+				rec, err := decodeOTLPRecord(sl.bytes, resource)
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, rec)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// decodes the attributes of an OTLP Resource into m.
+func decodeOTLPResource(b []byte, m map[string]any) error {
+	fields, err := parseProtobuf(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.num == 1 && f.wire == wireBytes {
+			k, v, err := decodeOTLPKeyValue(f.bytes)
+			if err != nil {
+				return err
+			}
+			m[k] = v
+		}
+	}
+	return nil
+}
+
+// decodes an OTLP LogRecord.
+func decodeOTLPRecord(b []byte, resource map[string]any) (map[string]any, error) {
+	fields, err := parseProtobuf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := make(map[string]any, len(resource)+len(fields))
+	for k, v := range resource {
+		rec[k] = v
+	}
+
+	var tm, observed uint64
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			tm = f.n
+		case 11:
+			observed = f.n
+		case 2:
+			if n := int(f.n); n > 0 && n <= 4*len(otlpLevels) {
+				if _, ok := rec["level"]; !ok {
+					rec["level"] = otlpLevels[(n-1)/4]
+				}
+			}
+		case 3:
+			rec["level"] = string(f.bytes)
+		case 5:
+			v, err := decodeOTLPValue(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			rec["message"] = v
+		case 6:
+			k, v, err := decodeOTLPKeyValue(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			rec[k] = v
+		case 9:
+			rec["trace_id"] = hex.EncodeToString(f.bytes)
+		case 10:
+			rec["span_id"] = hex.EncodeToString(f.bytes)
+		}
+	}
+
+	if tm == 0 {
+		tm = observed
+	}
+	if tm != 0 {
+		rec["time"] = time.Unix(0, int64(tm)).Format(time.RFC3339Nano)
+	}
+
+	return rec, nil
+}
+
+// decodes an OTLP KeyValue.
+func decodeOTLPKeyValue(b []byte) (string, any, error) {
+	fields, err := parseProtobuf(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var key string
+	var val any
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			if val, err = decodeOTLPValue(f.bytes); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return key, val, nil
+}
+
+// decodes an OTLP AnyValue into the type json would have unmarshalled it to.
+func decodeOTLPValue(b []byte) (any, error) {
+	fields, err := parseProtobuf(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			return string(f.bytes), nil
+		case 2:
+			return f.n != 0, nil
+		case 3:
+			return float64(int64(f.n)), nil
+		case 4:
+			return math.Float64frombits(f.n), nil
+		case 5:
+			items, err := parseProtobuf(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			arr := make([]any, 0, len(items))
+			for _, item := range items {
+				v, err := decodeOTLPValue(item.bytes)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			return arr, nil
+		case 6:
+			items, err := parseProtobuf(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			obj := make(map[string]any, len(items))
+			for _, item := range items {
+				k, v, err := decodeOTLPKeyValue(item.bytes)
+				if err != nil {
+					return nil, err
+				}
+				obj[k] = v
+			}
+			return obj, nil
+		case 7:
+			return hex.EncodeToString(f.bytes), nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+var outputMode = flag.String("output", "text", "how the entries are written (text, json), json writes the filtered entries back as compact json lines")
+
+// jsonOutput is set when the entries are written as json.
+var jsonOutput bool
+
+// setOutput checks -output and the flags that only make sense for text.
+func setOutput() error {
+	switch *outputMode {
+	case "text":
+		jsonOutput = false
+	case "json":
+		jsonOutput = true
+		switch {
+		case *tuiOn:
+			return fmt.Errorf("-output json can not be used with -tui")
+		case *rollupEvery > 0:
+			return fmt.Errorf("-output json can not be used with -rollup")
+		case contextWanted():
+			return fmt.Errorf("-output json can not be used with -A, -B or -C")
+		case *dedup:
+			return fmt.Errorf("-output json can not be used with -dedup")
+		}
+	default:
+		return fmt.Errorf("unknown -output %q", *outputMode)
+	}
+	return nil
+}
+
+// appendJSON appends the entry as a compact json object and a newline.  the
+// standard fields come first with the names of the default preset, then the
+// other fields that are shown in sorted order.  a field with the name of a
+// standard field that was written, like a time of an unexpected type, is left
+// out so no key is repeated.
+func appendJSON(b []byte, e *Entry) []byte {
+	written := make([]string, 0, 4)
+	b = append(b, '{')
+	if !e.Time.IsZero() {
+		t := e.Time
+		if timeZone != nil {
+			t = t.In(timeZone)
+		}
+		b = appendJSONField(b, "time", t.Format(time.RFC3339Nano))
+		written = append(written, "time")
+	}
+	b = appendJSONField(b, "level", e.Level)
+	written = append(written, "level")
+	if e.Message != "" {
+		b = appendJSONField(b, "message", e.Message)
+		written = append(written, "message")
+	}
+	if e.Error != "" && showKey("error") {
+		b = appendJSONField(b, "error", e.Error)
+		written = append(written, "error")
+	}
+
+	keys = keys[:0]
+	for k := range e.Fields {
+		if showKey(k) && !slices.Contains(written, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b = appendJSONField(b, k, e.Fields[k])
+	}
+	b = append(b, '}')
+	return append(b, '\n')
+}
+
+// appendJSONField appends a key and its value, with a comma unless it is the
+// first of the object.
+func appendJSONField(b []byte, k string, v any) []byte {
+	if b[len(b)-1] != '{' {
+		b = append(b, ',')
+	}
+	key, _ := json.Marshal(k)
+	b = append(b, key...)
+	b = append(b, ':')
+	val, err := json.Marshal(v)
+	if err != nil {
+		val, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return append(b, val...)
+}
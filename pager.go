@@ -0,0 +1,105 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+var pagerMode = flag.String("pager", "auto", "page the output of files (auto, always, never), auto pages files shown on a terminal")
+
+// usePager returns true if the output of the files is sent to a pager.
+// following, previewing and reading stdin are never paged unless asked for.
+func usePager(files []string) (bool, error) {
+	switch *pagerMode {
+	case "always":
+		return !*tailFile, nil
+	case "never":
+		return false, nil
+	case "auto":
+	default:
+		return false, fmt.Errorf("unknown -pager %q", *pagerMode)
+	}
+
+	if *tailFile || *previewLines > 0 || len(files) == 0 || !isTerminal(os.Stdout) {
+		return false, nil
+	}
+	for _, f := range files {
+		if f == "-" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// startPager runs $PAGER, or less -R, and sends the output to it.  the
+// returned func closes the output and waits for the pager to exit.  in auto
+// mode a pager that can not be started is skipped.
+func startPager() (func() error, error) {
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = []string{"less", "-R"}
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// like git, quit if the output fits on one screen and keep it shown.
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if *pagerMode == "auto" {
+			return func() error { return nil }, nil
+		}
+		return nil, fmt.Errorf("-pager: %w", err)
+	}
+
+	pw := &pagerWriter{w: w}
+	out = bufio.NewWriter(pw)
+	return func() error {
+		w.Close()
+		return cmd.Wait()
+	}, nil
+}
+
+// errPagerQuit stops the reading of the files once the pager was quit.
+var errPagerQuit = errors.New("the pager was quit")
+
+// pagerQuit is set when the pager was quit before the end of the output.
+var pagerQuit bool
+
+// pagerWriter writes to the pager.  quitting the pager before the end is not
+// an error, glogv just stops without reading the rest of the files.
+type pagerWriter struct {
+	w io.WriteCloser
+}
+
+func (p *pagerWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed) {
+		pagerQuit = true
+		return n, errPagerQuit
+	}
+	return n, err
+}
+
+// pagerError returns errPagerQuit once the pager was quit, so the files stop
+// being read.
+func pagerError() error {
+	if pagerQuit {
+		return errPagerQuit
+	}
+	return nil
+}
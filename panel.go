@@ -0,0 +1,325 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// how often the panel is redrawn, how many values of -panel-key it shows and
+// how wide it is as a pane of the -tui.
+const (
+	panelInterval = time.Second
+	panelValues   = 5
+	panelTUIWidth = 40
+)
+
+// the statistics panel shown at the bottom of the terminal with -panel.
+var pnl *panel
+
+// panelBucket holds the counts of one second of entries.
+type panelBucket struct {
+	sec    int64
+	levels map[string]int
+	values map[string]int
+}
+
+// sourceStatus is what the -status line of a source is made of.
+type sourceStatus struct {
+	label   string
+	path    string // the followed file, empty if the size is not known.
+	lines   int
+	dropped int
+	prev    int // lines at the previous draw.
+	last    time.Time
+	offset  int64
+}
+
+// panel keeps rolling counts per level and of the values of a field over the
+// last -panel-window with -panel, and the status of each source with
+// -status, and draws them below a scroll region for the entries or in a pane
+// on the right of the -tui.
+type panel struct {
+	counts  bool
+	key     string
+	window  time.Duration
+	buckets []panelBucket
+	sources []*sourceStatus
+	byLabel map[string]*sourceStatus
+	drawn   time.Time
+	rows    int
+	cols    int
+	out     []byte
+}
+
+// newPanel returns nil if stdout is not a terminal that is big enough.  the
+// -tui must be started first so the panel is drawn in it.
+func newPanel(counts bool, key string, window time.Duration, srcs []Source) *panel {
+	secs := int(window / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	p := &panel{counts: counts, key: key, window: window, buckets: make([]panelBucket, secs)}
+	for i := range p.buckets {
+		p.buckets[i].levels = make(map[string]int)
+		p.buckets[i].values = make(map[string]int)
+	}
+
+	if srcs != nil {
+		p.byLabel = make(map[string]*sourceStatus)
+		for _, src := range srcs {
+			st := &sourceStatus{label: src.Label()}
+			if ts, ok := src.(*tailSource); ok && !isFD(ts.path) {
+				st.path = ts.path
+			}
+			p.sources = append(p.sources, st)
+			p.byLabel[st.label] = st
+		}
+	}
+
+	if tui != nil {
+		p.cols = panelTUIWidth
+	} else if !p.resize() {
+		return nil
+	}
+	p.drawn = time.Now()
+	return p
+}
+
+// height is the number of terminal rows used by the panel.
+func (p *panel) height() int {
+	h := 1 // the separator.
+	if p.counts {
+		h++
+		if p.key != "" {
+			h += 1 + panelValues
+		}
+	}
+	return h + len(p.sources)
+}
+
+// resize sets the scroll region for the entries above the panel when the
+// size of the terminal changed and returns false if there is no terminal.
+func (p *panel) resize() bool {
+	rows, cols, ok := termSize()
+	if !ok || rows <= 2*p.height() {
+		return false
+	}
+	if rows == p.rows && cols == p.cols {
+		return true
+	}
+
+	// make room for the panel below the cursor the first time.
+	if p.rows == 0 {
+		fmt.Fprintf(out, "%s\033[%dA", strings.Repeat("\n", p.height()), p.height())
+	}
+	p.rows, p.cols = rows, cols
+
+	// the cursor is moved to the top by setting the region, so keep it.
+	fmt.Fprintf(out, "\0337\033[1;%dr\0338", rows-p.height())
+	return true
+}
+
+// read records a line read from a source for its -status.
+func (p *panel) read(src string, l logLine) {
+	st, ok := p.byLabel[src]
+	if !ok {
+		return
+	}
+	if l.dropped > 0 {
+		st.lines += l.dropped
+		st.dropped += l.dropped
+	} else {
+		st.lines++
+	}
+	st.last = time.Now()
+	if l.cur != nil {
+		st.offset = l.cur.Offset
+	}
+}
+
+// add counts an entry in the bucket of the current second.
+func (p *panel) add(e *Entry) {
+	if !p.counts {
+		return
+	}
+	sec := time.Now().Unix()
+	b := &p.buckets[sec%int64(len(p.buckets))]
+	if b.sec != sec {
+		b.sec = sec
+		clear(b.levels)
+		clear(b.values)
+	}
+
+	b.levels[e.Level]++
+	if p.key != "" {
+		if v, ok := e.Fields[p.key]; ok {
+			b.values[fmt.Sprint(v)]++
+		}
+	}
+}
+
+// draw shows the status of the sources and the counts of the buckets within
+// the window, in the -tui or below a line under the entries.
+func (p *panel) draw() {
+	if tui != nil {
+		tui.setPanel(p.text())
+		return
+	}
+	if !p.resize() {
+		return
+	}
+
+	row := p.rows - p.height() + 1
+	p.out = append(p.out[:0], "\0337"...)
+	p.out = p.appendRow(p.out, row, tagColor+strings.Repeat("─", p.cols)+colorReset)
+	for _, s := range p.text() {
+		row++
+		p.out = p.appendRow(p.out, row, s)
+	}
+	p.out = append(p.out, "\0338"...)
+	out.Write(p.out)
+	out.Flush()
+}
+
+// text returns the rows of the panel.
+func (p *panel) text() []string {
+	levels := make(map[string]int)
+	values := make(map[string]int)
+	since := time.Now().Add(-p.window).Unix()
+	for _, b := range p.buckets {
+		if b.sec <= since {
+			continue
+		}
+		for k, n := range b.levels {
+			levels[k] += n
+		}
+		for k, n := range b.values {
+			values[k] += n
+		}
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(p.drawn).Seconds()
+	p.drawn = now
+	rows := make([]string, 0, p.height())
+	for _, st := range p.sources {
+		rows = append(rows, p.status(st, now, elapsed))
+	}
+	if p.counts {
+		rows = p.appendCounts(rows, levels, values)
+	}
+	return rows
+}
+
+// status returns the -status line of a source.
+func (p *panel) status(st *sourceStatus, now time.Time, elapsed float64) string {
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(st.lines-st.prev) / elapsed
+	}
+	st.prev = st.lines
+
+	last := "no lines yet"
+	if !st.last.IsZero() {
+		last = "last " + now.Sub(st.last).Round(time.Second).String() + " ago"
+	}
+
+	behind := ""
+	if st.path != "" {
+		if fi, err := os.Stat(st.path); err == nil && fi.Size() >= st.offset {
+			behind = ", " + formatBytes(fi.Size()-st.offset) + " behind"
+		}
+	}
+
+	dropped := ""
+	if st.dropped > 0 {
+		dropped = fmt.Sprintf(", %d dropped", st.dropped)
+	}
+
+	// shorten the start of the label so the line fits.
+	text := fmt.Sprintf(" %.1f lines/s, %s%s%s", rate, last, behind, dropped)
+	label := st.label
+	if keep := p.cols - len(text); len(label) > keep {
+		if keep < 4 {
+			keep = 4
+		}
+		label = "..." + label[len(label)-keep+3:]
+	}
+	return tagColor + label + colorReset + text
+}
+
+// appendCounts appends the rows of the -panel counts.
+func (p *panel) appendCounts(rows []string, levels, values map[string]int) []string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%slast %v%s", tagColor, p.window, colorReset)
+	for _, name := range byCount(levels) {
+		fmt.Fprintf(&sb, "  %s%s%s %d", labelColor[name], format.LevelLabel(name), colorReset, levels[name])
+	}
+	rows = append(rows, sb.String())
+
+	if p.key != "" {
+		rows = append(rows, fmt.Sprintf("%stop %s%s", tagColor, p.key, colorReset))
+		top := byCount(values)
+		for i := 0; i < panelValues; i++ {
+			s := ""
+			if i < len(top) {
+				s = fmt.Sprintf("%8d %s", values[top[i]], top[i])
+				if len(s) > p.cols {
+					s = s[:p.cols]
+				}
+			}
+			rows = append(rows, s)
+		}
+	}
+
+	return rows
+}
+
+// appendRow clears a row of the terminal and writes s to it.
+func (p *panel) appendRow(b []byte, row int, s string) []byte {
+	b = fmt.Appendf(b, "\033[%d;1H\033[2K", row)
+	return append(b, s...)
+}
+
+// close gives the whole terminal back to the entries.
+func (p *panel) close() {
+	if tui != nil {
+		return
+	}
+	fmt.Fprintf(out, "\033[r\033[%d;1H\n", p.rows)
+}
+
+// formatBytes returns n in the largest unit that keeps it above 1.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// byCount returns the keys of m ordered by their counts.
+func byCount(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
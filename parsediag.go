@@ -0,0 +1,53 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// how much of a line is included in a -debug-parse diagnostic.
+const excerptLen = 200
+
+var debugParse = flag.Bool("debug-parse", false, "write a json diagnostic to stderr for every line that could not be parsed")
+
+// parseDiag is written to stderr with -debug-parse for a line that was not
+// displayed as a log entry.  the line number is left out when it is not
+// known.
+type parseDiag struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Reason  string `json:"reason"`
+	Excerpt string `json:"excerpt"`
+}
+
+// reportParse writes a -debug-parse diagnostic and counts the line for
+// -file-table, blank lines are not worth either.
+func reportParse(src string, lineNo int, reason string, b []byte) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return
+	}
+	if fileTable != nil {
+		countFileParseError(src)
+	}
+	if !*debugParse {
+		return
+	}
+	if len(b) > excerptLen {
+		b = b[:excerptLen]
+	}
+	js, err := json.Marshal(parseDiag{
+		File:    src,
+		Line:    lineNo,
+		Reason:  reason,
+		Excerpt: strings.ToValidUTF8(string(b), ""),
+	})
+	if err != nil {
+		return
+	}
+	os.Stderr.Write(append(js, '\n'))
+}
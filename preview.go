@@ -0,0 +1,111 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// spanFormat is the layout used for the time span of a previewed source.
+const spanFormat = "2006-01-02 15:04:05"
+
+// spanEntry is used to parse the timestamp of lines that are not displayed.
+var spanEntry = Entry{Fields: make(map[string]any)}
+
+// preview shows the first and last -preview lines of each source with the
+// number of omitted lines and the time span of the source between them.
+func preview(srcs []Source) error {
+	for _, s := range srcs {
+		if err := previewSource(s, *previewLines); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func previewSource(s Source, n int) error {
+	if err := s.Open(); err != nil {
+		return err
+	}
+	defer s.Close()
+
+	src := s.Label()
+	last := make([][]byte, 0, n)
+	next, total := 0, 0
+	var first, end time.Time
+
+	// loop until EOF, keeping the last n lines in a ring.
+	for {
+		l, err := s.Next()
+		if err == io.EOF {
+			flushDoc(src, reformatLine)
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if l.notice != "" {
+			continue
+		}
+
+		total++
+		if t := lineTime(src, l.data); !t.IsZero() {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(end) {
+				end = t
+			}
+		}
+
+		switch {
+		case total <= n:
+			show(src, l)
+			if err := pagerError(); err != nil {
+				return err
+			}
+		case len(last) < n:
+			last = append(last, bytes.Clone(l.data))
+		default:
+			last[next] = append(last[next][:0], l.data...)
+			next = (next + 1) % n
+		}
+	}
+
+	if omitted := total - n - len(last); omitted > 0 {
+		printNotice(fmt.Sprintf("%d lines omitted", omitted))
+	}
+
+	// keep the line numbers of the last lines correct.
+	lineNos[src] = total - len(last)
+	for i := range last {
+		reformat(src, last[(next+i)%len(last)])
+	}
+
+	printNotice(spanOf(src, total, first, end))
+
+	return nil
+}
+
+// lineTime returns the timestamp of a line without displaying it.
+func lineTime(src string, b []byte) time.Time {
+	if !parseEntry(src, b, &spanEntry) {
+		return time.Time{}
+	}
+	return spanEntry.Time
+}
+
+func spanOf(src string, total int, first, end time.Time) string {
+	if first.IsZero() {
+		return fmt.Sprintf("%s: %d lines, no timestamps", src, total)
+	}
+	if timeZone != nil {
+		first, end = first.In(timeZone), end.In(timeZone)
+	}
+
+	return fmt.Sprintf("%s: %d lines, %s to %s (%v)", src, total,
+		first.Format(spanFormat), end.Format(spanFormat), end.Sub(first).Round(time.Second))
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import "slices"
+
+// processFields are the fields about the process that some loggers add to
+// every line, which are shown out of the way of the other fields.
+type processFields struct {
+	prefix string   // shown dim before the message, like the name of the logger.
+	quiet  []string // shown dim after the other fields.
+	hidden []string // never shown, like the version of the log format.
+}
+
+// the process fields of the -preset loggers that have them.
+var presetFields = map[string]processFields{
+	"pino":   {prefix: "name", quiet: []string{"hostname", "pid"}, hidden: []string{"v"}},
+	"bunyan": {prefix: "name", quiet: []string{"hostname", "pid"}, hidden: []string{"v"}},
+}
+
+// has returns true if the key is one of the process fields.
+func (p *processFields) has(k string) bool {
+	if p.prefix == "" {
+		return false
+	}
+	return k == p.prefix || slices.Contains(p.quiet, k) || slices.Contains(p.hidden, k)
+}
+
+// appendPrefix appends the prefix field of the entry, if it has one.
+func appendPrefix(b []byte, e *Entry, p *processFields) []byte {
+	v, ok := e.Fields[p.prefix]
+	if p.prefix == "" || !ok {
+		return b
+	}
+	b = append(b, ' ')
+	b = append(b, dimColor...)
+	b = appendValue(b, v)
+	return append(b, colorReset...)
+}
+
+// appendQuiet appends the quiet fields of the entry that are not hidden by
+// -hide or -only.
+func appendQuiet(b []byte, e *Entry, p *processFields) []byte {
+	for _, k := range p.quiet {
+		v, ok := e.Fields[k]
+		if !ok || !showKey(k) {
+			continue
+		}
+		b = append(b, ' ')
+		b = append(b, dimColor...)
+		b = append(b, k...)
+		b = append(b, '=')
+		b = appendValue(b, v)
+		b = append(b, colorReset...)
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfile starts the cpu profile if -cpuprofile is set.  the returned
+// function stops it and writes the memory profile if -memprofile is set, it
+// must be called before exiting.
+func startProfile() (func() error, error) {
+	var cpu *os.File
+	if *cpuProfile != "" {
+		var err error
+		if cpu, err = os.Create(*cpuProfile); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+
+	stop := func() error {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return err
+			}
+		}
+
+		if *memProfile != "" {
+			mem, err := os.Create(*memProfile)
+			if err != nil {
+				return err
+			}
+			defer mem.Close()
+
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(mem); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errBadProtobuf = errors.New("malformed protobuf message")
+
+// pbField is a single field of a protobuf message.  which of the value
+// fields is set depends on the wire type.
+type pbField struct {
+	num   int
+	wire  int
+	n     uint64 // varint and fixed values.
+	bytes []byte // length delimited values, strings and embedded messages.
+}
+
+// parseProtobuf splits a protobuf message into its fields.  it is just
+// enough to read the handful of messages glogv accepts without generated
+// code.
+func parseProtobuf(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errBadProtobuf
+		}
+		b = b[n:]
+
+		f := pbField{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.n, n = binary.Uvarint(b); n <= 0 {
+				return nil, errBadProtobuf
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errBadProtobuf
+			}
+			f.n = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errBadProtobuf
+			}
+			f.n = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, errBadProtobuf
+			}
+			f.bytes = b[n : n+int(length)]
+			b = b[n+int(length):]
+		default:
+			return nil, errBadProtobuf
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
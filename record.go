@@ -0,0 +1,127 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// sessionVersion is the version of the -record session format, the first
+// line of a session is a sessionHeader and every line after it is a
+// recordedLine.
+const sessionVersion = 1
+
+type sessionHeader struct {
+	Version int      `json:"glogv_session"`
+	Sources []string `json:"sources"`
+}
+
+// recordedLine is a line as it was read along with when it was received.
+type recordedLine struct {
+	Time time.Time `json:"t"`
+	Src  string    `json:"src"`
+	Line string    `json:"line"`
+}
+
+// recorder writes the lines read by -tail to a -record session.
+type recorder struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+// newRecorder creates the session file and writes its header.
+func newRecorder(path string, srcs []Source) (*recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{file: file, w: bufio.NewWriter(file)}
+	r.enc = json.NewEncoder(r.w)
+
+	h := sessionHeader{Version: sessionVersion}
+	for _, s := range srcs {
+		h.Sources = append(h.Sources, s.Label())
+	}
+	if err := r.enc.Encode(h); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// write records a line, notices and dropped lines are not lines that were
+// read so they are left out.  a failed write is reported by flush.
+func (r *recorder) write(src string, l logLine) {
+	if l.notice != "" || l.dropped > 0 {
+		return
+	}
+	r.enc.Encode(recordedLine{Time: time.Now(), Src: src, Line: string(l.data)})
+}
+
+// flush writes the recorded lines to the file.
+func (r *recorder) flush() error {
+	return r.w.Flush()
+}
+
+func (r *recorder) close() error {
+	return errors.Join(r.w.Flush(), r.file.Close())
+}
+
+// runReplay implements the replay subcommand, which displays the lines of a
+// -record session as if they were being read again.
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 0, "replay at the given multiple of the recorded pace, 0 is as fast as possible")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: glogv replay [-speed n] session")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(bufio.NewReader(file))
+
+	var h sessionHeader
+	if err := dec.Decode(&h); err != nil || h.Version == 0 {
+		return fmt.Errorf("%s is not a glogv session", fs.Arg(0))
+	}
+	if h.Version > sessionVersion {
+		return fmt.Errorf("%s is a newer version %d session", fs.Arg(0), h.Version)
+	}
+	if err := setLabels(h.Sources, false); err != nil {
+		return err
+	}
+	flushEach = *speed > 0
+
+	var first, start time.Time
+	for dec.More() {
+		var rec recordedLine
+		if err := dec.Decode(&rec); err != nil {
+			return err
+		}
+		if *speed > 0 {
+			if first.IsZero() {
+				first, start = rec.Time, time.Now()
+			}
+			wait := time.Duration(float64(rec.Time.Sub(first)) / *speed)
+			time.Sleep(time.Until(start.Add(wait)))
+		}
+		show(rec.Src, logLine{data: []byte(rec.Line)})
+	}
+
+	flushDocs()
+	finishRollup()
+	return nil
+}
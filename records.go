@@ -0,0 +1,56 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/goccy/go-json"
+)
+
+// recordsKey is the key of the array that AWS CloudTrail wraps its events in.
+var recordsKey = []byte(`"Records"`)
+
+// returns true if the line is an object that starts with a Records array.
+func isRecords(b []byte) bool {
+	b = bytes.TrimLeft(b[1:], " \t\r\n")
+	return bytes.HasPrefix(b, recordsKey)
+}
+
+// unwrapRecords displays each object of a {"Records":[...]} wrapper as its
+// own entry and returns false if Records is not an array.
+func unwrapRecords(b []byte, cfg *sourceConfig) bool {
+	// skip the opening brace and the key to get to the array.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for i := 0; i < 2; i++ {
+		if _, err := dec.Token(); err != nil {
+			return false
+		}
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return false
+	}
+
+	var rec json.RawMessage
+	for dec.More() {
+		rec = rec[:0]
+		if err := dec.Decode(&rec); err != nil {
+			reportParse(entry.Source, entry.LineNo, err.Error(), b)
+			if entry.LineNo == 0 {
+				printWarning(fmt.Sprintf("%s: %v", entry.Source, err))
+			} else {
+				printWarning(fmt.Sprintf("%s:%d: %v", entry.Source, entry.LineNo, err))
+			}
+			return true
+		}
+		if err := parseJSON(&entry, rec, &sourceConfig{json: cfg.records}); err != nil {
+			reportParse(entry.Source, entry.LineNo, err.Error(), rec)
+			printUnparsed(entry.Source, rec)
+			continue
+		}
+		entry.Raw = rec
+		render(&entry, "")
+	}
+
+	return true
+}
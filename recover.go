@@ -0,0 +1,114 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/goccy/go-json"
+)
+
+// recoverLine makes a best effort to display a line that looks like json but
+// could not be unmarshalled.  concatenated objects are displayed one at a
+// time, the valid part of a truncated object is displayed with a note, and
+// anything that still can not be parsed is displayed as is so it is never
+// silently lost.
+func recoverLine(e *Entry, b []byte, keys *fieldKeys) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		start := dec.InputOffset()
+		clear(e.Fields)
+		if err := dec.Decode(&e.Fields); err != nil {
+			rest := bytes.TrimSpace(b[start:])
+			if len(rest) == 0 {
+				return
+			}
+			if salvage(e, rest) {
+				extract(e, keys)
+				render(e, "truncated")
+				return
+			}
+			printUnparsed(e.Source, rest)
+			return
+		}
+		extract(e, keys)
+		render(e, "")
+	}
+}
+
+// salvage reads as many key/value pairs as it can from the start of a broken
+// json object into e.Fields and returns true if it found any.  the value that
+// is cut off is kept as the text that was read of it, so a truncated message
+// is still shown.
+func salvage(e *Entry, b []byte) bool {
+	clear(e.Fields)
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		key, ok := tok.(string)
+		if !ok {
+			break
+		}
+		var val any
+		at := dec.InputOffset()
+		if err := dec.Decode(&val); err != nil {
+			if partial := partialValue(b[at:]); partial != "" {
+				e.Fields[key] = partial
+			}
+			break
+		}
+		e.Fields[key] = val
+	}
+
+	return len(e.Fields) > 0
+}
+
+// partialValue returns the text of a value that was cut off, without the
+// quote of a string.
+func partialValue(b []byte) string {
+	b = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(b), []byte(":")))
+	if len(b) == 0 || b[0] != '"' {
+		return string(b)
+	}
+	if s, err := strconv.Unquote(string(b) + `"`); err == nil {
+		return s
+	}
+	return string(b[1:])
+}
+
+// displays a line that could not be parsed as is, flagged as unparsed.  it is
+// filtered like the lines that are not log entries.
+func printUnparsed(src string, b []byte) {
+	if *jsonOnly || *rollupEvery > 0 || jsonOutput {
+		return
+	}
+	if !keepPlain(b) {
+		if contextWanted() {
+			addContext(appendUnparsed(line[:0], src, b), src)
+		}
+		return
+	}
+	printContext()
+	writeRaw(b)
+	line = appendUnparsed(line[:0], src, b)
+	writeLine(line, src, nil)
+	startContext()
+}
+
+// appendUnparsed appends a line that could not be parsed and a newline.
+func appendUnparsed(b []byte, src string, unparsed []byte) []byte {
+	b = appendLabel(b, src)
+	b = append(b, warnColor...)
+	b = append(b, "[unparsed] "...)
+	b = append(b, colorReset...)
+	b = append(b, unparsed...)
+	return append(b, '\n')
+}
@@ -0,0 +1,263 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// savedFilters holds the flags, filters, keys and colors in effect so a
+// reload that fails can put them back.
+type savedFilters struct {
+	flags         map[string]any // the value of each flag, a copy of the lists.
+	colors        [2]map[string]string
+	otherColors   [4]string
+	configColors  map[string]string
+	hide, only    []string
+	includeRules  map[string][]valueRule
+	excludeRules  []valueRule
+	grepRe        *regexp.Regexp
+	grepKeyRe     *regexp.Regexp
+	grepField     string
+	highlights    [2][]highlightRule
+	where         exprNode
+	since, until  time.Time
+	staticFields  []staticField
+	config        sourceConfig
+	configs       map[string]sourceConfig
+	numericLevels format.NumericLevels
+	timeLayouts   []string
+	timeNames     *strings.Replacer
+}
+
+func saveFilters() savedFilters {
+	s := savedFilters{
+		hide:          hidePatterns,
+		only:          onlyPatterns,
+		includeRules:  includeRules,
+		excludeRules:  excludeRules,
+		grepRe:        grepRe,
+		grepKeyRe:     grepKeyRe,
+		grepField:     grepField,
+		highlights:    [2][]highlightRule{lineHighlights, keyHighlights},
+		where:         whereRule,
+		since:         since,
+		until:         until,
+		staticFields:  staticFields,
+		config:        defaultConfig,
+		configs:       make(map[string]sourceConfig, len(sourceConfigs)),
+		numericLevels: numericLevels,
+		timeLayouts:   timeLayouts,
+		timeNames:     timeNames,
+	}
+	for label, cfg := range sourceConfigs {
+		s.configs[label] = *cfg
+	}
+
+	// the color maps are replaced by a reload, not changed.
+	s.flags = make(map[string]any)
+	flag.VisitAll(func(f *flag.Flag) {
+		if l := listValue(f); l != nil {
+			s.flags[f.Name] = slices.Clone(*l)
+		} else {
+			s.flags[f.Name] = f.Value.String()
+		}
+	})
+	s.colors = [2]map[string]string{color, labelColor}
+	s.otherColors = [4]string{timeColor, tagColor, infoColor, warnColor}
+	s.configColors = configColors
+	return s
+}
+
+// listValue returns the values of a flag that appends to a list when it is
+// set, nil for other flags.
+func listValue(f *flag.Flag) *[]string {
+	switch l := f.Value.(type) {
+	case *listFlag:
+		return (*[]string)(l)
+	case *expectFlag:
+		return (*[]string)(l)
+	}
+	return nil
+}
+
+func (s savedFilters) restore() {
+	hidePatterns, onlyPatterns = s.hide, s.only
+	includeRules, excludeRules = s.includeRules, s.excludeRules
+	grepRe, grepKeyRe, grepField = s.grepRe, s.grepKeyRe, s.grepField
+	lineHighlights, keyHighlights = s.highlights[0], s.highlights[1]
+	whereRule = s.where
+	since, until = s.since, s.until
+	staticFields = s.staticFields
+	clear(shownKeys)
+	defaultConfig = s.config
+	for label, cfg := range s.configs {
+		*sourceConfigs[label] = cfg
+	}
+	numericLevels = s.numericLevels
+	timeLayouts, timeNames = s.timeLayouts, s.timeNames
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if followFlags[f.Name] {
+			return
+		}
+		if l := listValue(f); l != nil {
+			*l = s.flags[f.Name].([]string)
+		} else {
+			f.Value.Set(s.flags[f.Name].(string))
+		}
+	})
+	color, labelColor = s.colors[0], s.colors[1]
+	timeColor, tagColor, infoColor, warnColor = s.otherColors[0], s.otherColors[1], s.otherColors[2], s.otherColors[3]
+	configColors = s.configColors
+}
+
+// followFlags are read by the goroutines following the sources, so a reload
+// keeps them as they were when following started.
+var followFlags = map[string]bool{
+	"retry":         true,
+	"F":             true,
+	"drop":          true,
+	"max-line-size": true,
+}
+
+// reloading is set while the config file is read again.
+var reloading bool
+
+// reloadConfig reads the config file again and applies its filters, keys and
+// colors, flags given on the command line still take precedence.  everything
+// is left as it was if the file has an error.
+func reloadConfig() error {
+	saved := saveFilters()
+
+	// a flag that was removed from the file goes back to its default.
+	flag.VisitAll(func(f *flag.Flag) {
+		if cmdlineFlags[f.Name] || noConfigFlags[f.Name] || followFlags[f.Name] {
+			return
+		}
+		if l := listValue(f); l != nil {
+			*l = nil
+			return
+		}
+		f.Value.Set(f.DefValue)
+	})
+
+	configColors = nil
+
+	reloading = true
+	err := loadConfigFile()
+	reloading = false
+	if err == nil {
+		err = applyFilters()
+	}
+	if err == nil {
+		err = applyKeys()
+	}
+	if err == nil && colorsOn {
+		err = reapplyColors()
+	}
+	if err != nil {
+		saved.restore()
+	}
+	return err
+}
+
+// showReload reloads the config file and shows whether it worked.
+func showReload() {
+	if err := reloadConfig(); err != nil {
+		printWarning(fmt.Sprintf("config not reloaded: %v", err))
+	} else {
+		printNotice("config reloaded")
+	}
+}
+
+// applyKeys sets the keys of the standard fields, the layout of times and the
+// numeric levels.
+func applyKeys() error {
+	if err := resetSourceConfigs(); err != nil {
+		return err
+	}
+	if err := setTimeParse(); err != nil {
+		return err
+	}
+	if err := setTimeLocale(); err != nil {
+		return err
+	}
+	numericLevels = format.DefaultNumericLevels
+	return setNumericLevels(*levelNums)
+}
+
+// reapplyColors sets the colors of the theme and the config file again.  the
+// theme picked for a light terminal is kept unless another one is chosen.
+func reapplyColors() error {
+	name := *themeName
+	if lightTerminal && name == "default" {
+		name = "light"
+	}
+	colors, err := colorOverrides()
+	if err != nil {
+		return err
+	}
+	if err := applyTheme(name); err != nil {
+		return err
+	}
+	applyColors(colors)
+	return nil
+}
+
+// applyFilters parses the flags of the filters that decide which entries and
+// fields are shown.
+func applyFilters() error {
+	if err := setKeyFilters(); err != nil {
+		return err
+	}
+	clear(shownKeys)
+	if err := setValueFilters(); err != nil {
+		return err
+	}
+	if err := setStaticFields(); err != nil {
+		return err
+	}
+	return setTimeRange(time.Now())
+}
+
+// configWatcher notices when the config file changes.  the file is polled
+// rather than watched so it works the same everywhere, and is noticed when it
+// is replaced by an editor or created after glogv was started.
+type configWatcher struct {
+	path string
+	mod  time.Time
+	size int64
+}
+
+func newConfigWatcher() *configWatcher {
+	path, _ := configPath()
+	w := &configWatcher{path: path}
+	w.changed()
+	return w
+}
+
+// changed returns true if the file was written, created or removed since the
+// last call.
+func (w *configWatcher) changed() bool {
+	if w.path == "" {
+		return false
+	}
+	var mod time.Time
+	size := int64(-1)
+	if fi, err := os.Stat(w.path); err == nil {
+		mod, size = fi.ModTime(), fi.Size()
+	}
+	if mod.Equal(w.mod) && size == w.size {
+		return false
+	}
+	w.mod, w.size = mod, size
+	return true
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	resolveKeys    = flag.String("resolve", "", "replace the ip addresses in the given comma separated fields with their host names")
+	resolveTimeout = flag.Duration("resolve-timeout", 500*time.Millisecond, "how long to wait for a -resolve lookup")
+)
+
+// maxResolved is the number of addresses remembered by -resolve, failed
+// lookups are remembered as well so an address is only looked up once.
+// maxLookups is the number of lookups done at the same time while following.
+const (
+	maxResolved = 10000
+	maxLookups  = 16
+)
+
+// the host name of each address looked up, empty if it has none.  while
+// following the lookups are done in the background and the address is shown
+// as it is until its lookup is done, so the output is not held up.
+var (
+	resolved   = make(map[string]string)
+	resolvedMu sync.Mutex
+	lookups    = make(chan struct{}, maxLookups)
+)
+
+// resolveFields replaces the ip addresses of the -resolve fields of the entry
+// with their host names.
+func resolveFields(e *Entry) {
+	for _, key := range strings.Split(*resolveKeys, ",") {
+		addr, ok := e.Fields[key].(string)
+		if !ok {
+			continue
+		}
+		if host := hostName(addr); host != "" {
+			e.Fields[key] = host
+		}
+	}
+}
+
+// hostName returns the host name of the address, or an empty string if the
+// address is not an ip or can not be looked up in time.  an address may have
+// a port, which is kept.
+func hostName(addr string) string {
+	ip, port := addr, ""
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		ip, port = h, p
+	}
+	if net.ParseIP(ip) == nil {
+		return ""
+	}
+
+	resolvedMu.Lock()
+	host, ok := resolved[ip]
+	if !ok && len(resolved) >= maxResolved {
+		clear(resolved)
+	}
+	resolvedMu.Unlock()
+	if !ok {
+		if *tailFile {
+			lookupLater(ip)
+			return ""
+		}
+		host = lookupAddr(ip, *resolveTimeout)
+		setResolved(ip, host)
+	}
+	if host == "" || port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// lookupLater looks up the address in the background, unless it is already
+// being looked up or too many lookups are.
+func lookupLater(ip string) {
+	select {
+	case lookups <- struct{}{}:
+	default:
+		return
+	}
+	resolvedMu.Lock()
+	if _, ok := resolved[ip]; ok {
+		resolvedMu.Unlock()
+		<-lookups
+		return
+	}
+	// an address being looked up has no host name until it is done.
+	resolved[ip] = ""
+	resolvedMu.Unlock()
+
+	timeout := *resolveTimeout
+	go func() {
+		defer func() { <-lookups }()
+		setResolved(ip, lookupAddr(ip, timeout))
+	}()
+}
+
+func setResolved(ip, host string) {
+	resolvedMu.Lock()
+	resolved[ip] = host
+	resolvedMu.Unlock()
+}
+
+func lookupAddr(ip string, timeout time.Duration) string {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
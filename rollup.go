@@ -0,0 +1,159 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// rollupWindow is one -rollup window of entries.
+type rollupWindow struct {
+	start   time.Time
+	end     time.Time
+	levels  map[string]int
+	msgs    *clusters
+	maxNum  float64
+	maxDur  time.Duration
+	latency string // the key of the largest latency.
+	hasNum  bool
+}
+
+// rollupLevels is the order the counts of a window are shown in, so they
+// line up from one window to the next.
+var rollupLevels = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
+
+// the window being collected, nil before the first entry.
+var rollup *rollupWindow
+
+// latencyKeys are the fields checked for the max latency of a window.
+var latencyKeys []string
+
+// rollupSkipped counts the entries that are not in any window, as they are
+// before the window being collected or have no time outside of -tail.
+var rollupSkipped int
+
+// addRollup counts the entry in its window, printing the summary of the
+// previous window when the entry is past its end.  entries without a time
+// are counted at the time they are read while following.
+func addRollup(e *Entry) {
+	t := e.Time
+	if t.IsZero() {
+		if !*tailFile {
+			rollupSkipped++
+			return
+		}
+		t = time.Now()
+	}
+
+	if rollup != nil && t.Before(rollup.start) {
+		rollupSkipped++
+		return
+	}
+	if rollup != nil && !t.Before(rollup.end) {
+		printRollup()
+	}
+	if rollup == nil {
+		start := t.Truncate(*rollupEvery)
+		rollup = &rollupWindow{
+			start:  start,
+			end:    start.Add(*rollupEvery),
+			levels: make(map[string]int),
+			msgs:   newClusters(),
+		}
+	}
+
+	rollup.levels[e.Level]++
+	if e.Message != "" {
+		rollup.msgs.add(e.Message)
+	}
+	for _, k := range latencyKeys {
+		if v, ok := e.Fields[k]; ok {
+			rollup.addLatency(k, v)
+			break
+		}
+	}
+}
+
+// addLatency keeps the largest latency, numbers and durations like "32ms"
+// are kept apart as the unit of a number is not known.
+func (w *rollupWindow) addLatency(key string, v any) {
+	switch val := v.(type) {
+	case float64:
+		if !w.hasNum || val > w.maxNum {
+			w.maxNum, w.hasNum, w.latency = val, true, key
+		}
+	case string:
+		if d, err := time.ParseDuration(val); err == nil && d > w.maxDur {
+			w.maxDur, w.latency = d, key
+		}
+	}
+}
+
+// rollupTick prints the summary of the window once the clock has passed
+// its end, so a quiet follow still sees a heartbeat.
+func rollupTick(now time.Time) {
+	if rollup != nil && now.After(rollup.end.Add(time.Second)) {
+		printRollup()
+	}
+}
+
+// finishRollup prints the last window, which is not complete, and how many
+// entries were not in any window.
+func finishRollup() {
+	printRollup()
+	if rollupSkipped > 0 {
+		printNotice(fmt.Sprintf("%d entries before their window or without a time are not in the rollup", rollupSkipped))
+		rollupSkipped = 0
+	}
+}
+
+// printRollup prints the summary of the current window and starts over.
+func printRollup() {
+	if rollup == nil {
+		return
+	}
+	w := rollup
+	rollup = nil
+
+	line = line[:0]
+	line = appendTime(line, w.start)
+	for _, name := range rollupLevels {
+		if w.levels[name] == 0 {
+			continue
+		}
+		line = append(line, ' ')
+		line = append(line, labelColor[name]...)
+		line = append(line, format.LevelLabel(name)...)
+		line = append(line, colorReset...)
+		line = fmt.Appendf(line, " %d", w.levels[name])
+	}
+
+	if top := w.msgs.top(1); len(top) > 0 {
+		line = append(line, tagColor...)
+		line = append(line, " top="...)
+		line = append(line, infoColor...)
+		line = append(line, strings.Join(top[0].tokens, " ")...)
+		line = fmt.Appendf(line, " (%d)", top[0].count)
+	}
+
+	switch {
+	case w.maxDur > 0:
+		line = append(line, tagColor...)
+		line = fmt.Appendf(line, " max %s=", w.latency)
+		line = append(line, warnColor...)
+		line = append(line, w.maxDur.String()...)
+	case w.hasNum:
+		line = append(line, tagColor...)
+		line = fmt.Appendf(line, " max %s=", w.latency)
+		line = append(line, warnColor...)
+		line = appendNumber(line, w.maxNum)
+	}
+
+	line = append(line, colorReset...)
+	line = append(line, '\n')
+	out.Write(line)
+	writeFormatted(line)
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var sampling = flag.Bool("sampling", true, "show the sampling counters of an entry as a note, like (~40 similar suppressed upstream)")
+
+// what a sampling field says about an entry.
+type samplingKind int
+
+const (
+	suppressedField samplingKind = iota + 1 // how many similar entries were not written.
+	sampleRateField                         // the rate the entry was sampled at.
+	sampledField                            // whether the entry was sampled.
+)
+
+// the fields loggers and samplers use for their counters.
+var samplingKeys = map[string]samplingKind{
+	"suppressed":     suppressedField,
+	"sampled_out":    suppressedField,
+	"repeat_count":   suppressedField,
+	"similar_count":  suppressedField,
+	"suppress_count": suppressedField,
+	"sample_rate":    sampleRateField,
+	"sampling_rate":  sampleRateField,
+	"samplerate":     sampleRateField,
+	"sampled":        sampledField,
+}
+
+// isSampling returns true if a field is shown as a sampling note instead of
+// in the key/value section.  only values the note says something about are,
+// so nothing suppressed, a rate of 1 or an entry that was not sampled is
+// still shown as a field.
+func isSampling(key string, v any) bool {
+	if !*sampling {
+		return false
+	}
+	switch samplingKeys[key] {
+	case suppressedField:
+		n, ok := toNumber(v)
+		return ok && n > 0
+	case sampleRateField:
+		n, ok := toNumber(v)
+		return ok && n > 0 && n < 1
+	case sampledField:
+		sampled, _ := v.(bool)
+		return sampled
+	}
+	return false
+}
+
+// appendSampling appends a dim note for the sampling counters of the entry,
+// so a sampled log is not mistaken for a quiet one.  the counters are looked
+// up by name as most entries have none of them.
+func appendSampling(b []byte, e *Entry) []byte {
+	var suppressed, rate float64
+	sampled := false
+	for k, kind := range samplingKeys {
+		v, ok := e.Fields[k]
+		if !ok || !isSampling(k, v) {
+			continue
+		}
+		switch kind {
+		case suppressedField:
+			n, _ := toNumber(v)
+			suppressed += n
+		case sampleRateField:
+			rate, _ = toNumber(v)
+		case sampledField:
+			sampled, _ = v.(bool)
+		}
+	}
+
+	// each of the hidden fields is part of the note.
+	var notes []string
+	if suppressed > 0 {
+		notes = append(notes, "~"+strconv.FormatFloat(suppressed, 'f', -1, 64)+" similar suppressed upstream")
+	}
+	if rate > 0 {
+		notes = append(notes, "sampled 1 in "+strconv.FormatFloat(math.Round(1/rate), 'f', -1, 64)+" upstream")
+	} else if sampled {
+		notes = append(notes, "sampled upstream")
+	}
+	if notes == nil {
+		return b
+	}
+	b = append(b, ' ')
+	b = append(b, tagColor...)
+	b = append(b, '(')
+	b = append(b, strings.Join(notes, ", ")...)
+	return append(b, ')')
+}
+
+// toNumber returns a json number, or a number written as a string.
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		return parseNumber(n)
+	}
+	return 0, false
+}
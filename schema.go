@@ -0,0 +1,226 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+	"github.com/goccy/go-json"
+)
+
+// maxExample is the length examples are cut to in the schema report.
+const maxExample = 32
+
+// keySchema is what the schema report has seen of a key.
+type keySchema struct {
+	count    int
+	types    map[string]int
+	examples []string
+}
+
+// schema counts the keys of the entries, their types and a few examples.
+type schema struct {
+	entries  int
+	keys     map[string]*keySchema
+	examples int
+	fields   map[string]any // the keys and values of the line being counted.
+}
+
+func newSchema(examples int) *schema {
+	return &schema{keys: make(map[string]*keySchema), examples: examples, fields: make(map[string]any)}
+}
+
+// runSchema implements the schema subcommand, which reports every key of
+// the entries with the types it had, how many entries had it and examples.
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	examples := fs.Int("examples", 3, "number of different values shown for each key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	srcs, err := parseSources(files)
+	if err != nil {
+		return err
+	}
+
+	sc := newSchema(*examples)
+	e := Entry{Fields: make(map[string]any)}
+	fn := func(s Source) error {
+		if err := s.Open(); err != nil {
+			return err
+		}
+		defer s.Close()
+
+		for {
+			l, err := s.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if l.notice == "" {
+				sc.add(s.Label(), l.data, &e)
+			}
+		}
+	}
+
+	for _, s := range srcs {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	sc.print(os.Stdout)
+	return nil
+}
+
+// add counts the keys of a line as they were written, before the standard
+// fields are taken out of them.  lines that are not entries are skipped.
+func (sc *schema) add(src string, b []byte, e *Entry) {
+	clear(sc.fields)
+	cfg := configOf(src)
+	switch formatOf(src, b, cfg.format) {
+	case "json":
+		if !isJSON(b) || json.Unmarshal(b, &sc.fields) != nil {
+			return
+		}
+		if !*expand {
+			format.Flatten(sc.fields)
+		}
+	case "logfmt":
+		ok := scanLogfmt(b, func(k string, v string, hasValue bool) {
+			if hasValue {
+				sc.fields[k] = v
+			} else {
+				sc.fields[k] = true
+			}
+		})
+		if !ok {
+			return
+		}
+	default:
+		// the text formats name their fields themselves, the level is left
+		// out as it is not written with a key.
+		if !parseEntry(src, b, e) {
+			return
+		}
+		maps.Copy(sc.fields, e.Fields)
+		if !e.Time.IsZero() {
+			sc.fields["time"] = e.Time.Format(time.RFC3339)
+		}
+		if e.Message != "" {
+			sc.fields["message"] = e.Message
+		}
+		if e.Error != "" {
+			sc.fields["error"] = e.Error
+		}
+	}
+
+	sc.entries++
+	for k, v := range sc.fields {
+		sc.addValue(k, jsonType(v), string(appendValue(nil, v)))
+	}
+}
+
+func (sc *schema) addValue(key, typ, val string) {
+	ks, ok := sc.keys[key]
+	if !ok {
+		ks = &keySchema{types: make(map[string]int)}
+		sc.keys[key] = ks
+	}
+	ks.count++
+	ks.types[typ]++
+	if len(ks.examples) < sc.examples {
+		if len(val) > maxExample {
+			val = val[:maxExample] + "..."
+		}
+		for _, ex := range ks.examples {
+			if ex == val {
+				return
+			}
+		}
+		ks.examples = append(ks.examples, val)
+	}
+}
+
+// jsonType returns the json type of a value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case nil:
+		return "null"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// print prints a line for each key, the most common first.
+func (sc *schema) print(w io.Writer) {
+	fmt.Fprintf(w, "%d entries\n", sc.entries)
+	if sc.entries == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(sc.keys))
+	width := len("key")
+	for k := range sc.keys {
+		keys = append(keys, k)
+		width = max(width, len(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := sc.keys[keys[i]], sc.keys[keys[j]]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Fprintf(w, "\n%s%-*s %6s  %-22s %s%s\n", tagColor, width, "key", "fill", "types", "examples", colorReset)
+	for _, k := range keys {
+		ks := sc.keys[k]
+		fill := 100 * float64(ks.count) / float64(sc.entries)
+		fmt.Fprintf(w, "%-*s %5.1f%%  %-22s %s\n", width, k, fill, ks.typeList(), strings.Join(ks.examples, ", "))
+	}
+}
+
+// typeList returns the types of a key, with the share of each if it had
+// more than one.
+func (ks *keySchema) typeList() string {
+	types := make([]string, 0, len(ks.types))
+	for t := range ks.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if ks.types[types[i]] != ks.types[types[j]] {
+			return ks.types[types[i]] > ks.types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	if len(types) == 1 {
+		return types[0]
+	}
+	for i, t := range types {
+		types[i] = fmt.Sprintf("%s %.0f%%", t, 100*float64(ks.types[t])/float64(ks.count))
+	}
+	return strings.Join(types, ", ")
+}
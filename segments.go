@@ -0,0 +1,65 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// powerline separator glyph, needs a powerline or nerd font.
+const segmentSep = "\ue0b0"
+
+// 256 color background of the level segment.
+var segmentColor = map[string]int{
+	"info":  28,
+	"warn":  178,
+	"debug": 30,
+	"error": 160,
+	"panic": 90,
+	"fatal": 90,
+	"trace": 30,
+}
+
+// colors of the time segment.
+const (
+	segmentTimeFg = 250
+	segmentTimeBg = 238
+	segmentText   = 231
+)
+
+// formats the time and level as colored blocks joined by powerline
+// separators for the -style segments option.
+func appendSegments(b []byte, t time.Time, l string) []byte {
+	bg, ok := segmentColor[l]
+	if !ok {
+		bg = segmentColor["info"]
+	}
+
+	b = appendSGR(b, segmentTimeFg, segmentTimeBg)
+	b = append(b, ' ')
+	b = appendDisplayTime(b, t)
+	b = append(b, ' ')
+	b = appendSGR(b, segmentTimeBg, bg)
+	b = append(b, segmentSep...)
+	b = appendSGR(b, segmentText, bg)
+	b = append(b, ' ')
+	b = append(b, format.LevelLabel(l)...)
+	b = append(b, ' ')
+	b = append(b, colorReset...)
+	b = append(b, "\033[38;5;"...)
+	b = strconv.AppendInt(b, int64(bg), 10)
+	b = append(b, 'm')
+	b = append(b, segmentSep...)
+	return append(b, colorReset...)
+}
+
+// appends the escape code for a 256 color foreground and background.
+func appendSGR(b []byte, fg, bg int) []byte {
+	b = append(b, "\033[0;38;5;"...)
+	b = strconv.AppendInt(b, int64(fg), 10)
+	b = append(b, ";48;5;"...)
+	b = strconv.AppendInt(b, int64(bg), 10)
+	return append(b, 'm')
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// last sequence number seen for each source.
+var lastSeq = make(map[string]int64)
+
+// checkSequence compares the sequence counter of a log entry with the last
+// one seen from the same source.  a warning is returned if lines appear to
+// be missing or the counter was reset, otherwise an empty string is returned.
+func checkSequence(src string, v any) string {
+	var seq int64
+	switch val := v.(type) {
+	case float64:
+		seq = int64(val)
+	case string:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return ""
+		}
+		seq = n
+	default:
+		return ""
+	}
+
+	last, ok := lastSeq[src]
+	lastSeq[src] = seq
+	if !ok {
+		return ""
+	}
+
+	switch {
+	case seq == last+1:
+		return ""
+	case seq > last+1:
+		return fmt.Sprintf("%s: %d line(s) missing, %s jumped from %d to %d", src, seq-last-1, *seqKey, last, seq)
+	case seq == last:
+		return fmt.Sprintf("%s: %s %d repeated", src, *seqKey, seq)
+	default:
+		return fmt.Sprintf("%s: %s reset from %d to %d", src, *seqKey, last, seq)
+	}
+}
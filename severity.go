@@ -0,0 +1,65 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+var (
+	exitBySeverity = flag.Bool("exit-by-severity", false, "exit with 1 if warnings were shown and 2 if errors were shown")
+	failOn         = flag.String("fail-on", "", "exit with 3 if an entry at or above the given level was read, shown or not")
+)
+
+// the exit codes of -exit-by-severity and -fail-on, errorExitCode is still
+// used when glogv itself fails.
+const (
+	warnSeenExitCode  = 1 // exit code if a warning was shown.
+	errorSeenExitCode = 2 // exit code if an error, fatal or panic was shown.
+	failOnExitCode    = 3 // exit code if an entry at the -fail-on level was read.
+)
+
+// severityExit is the exit code of the most severe entry shown so far.
+var severityExit int
+
+// noteSeverity raises the -exit-by-severity exit code to that of the level.
+func noteSeverity(level string) {
+	code := 0
+	switch level {
+	case "warn":
+		code = warnSeenExitCode
+	case "error", "fatal", "panic":
+		code = errorSeenExitCode
+	}
+	severityExit = max(severityExit, code)
+}
+
+// the rank of the -fail-on level in format.Levels, -1 if it is not set, and
+// whether an entry at or above it was read.
+var (
+	failRank = -1
+	failSeen bool
+)
+
+// setFailOn parses the -fail-on level.
+func setFailOn() error {
+	failRank = -1
+	if *failOn == "" {
+		return nil
+	}
+	level := format.NormalizeLevel(*failOn)
+	if failRank = slices.Index(format.Levels, level); failRank < 0 {
+		return fmt.Errorf("unknown -fail-on level %q", *failOn)
+	}
+	return nil
+}
+
+// noteFailure notes an entry at or above the -fail-on level.
+func noteFailure(level string) {
+	if failRank >= 0 && slices.Index(format.Levels, level) >= failRank {
+		failSeen = true
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+var sloSpecs listFlag
+
+func init() {
+	flag.Var(&sloSpecs, "slo", "mark entries whose field does not meet key<op>value, like latency_ms<=250, and report how many with -stats, may be repeated")
+}
+
+// sloRule is an objective the entries with its field are expected to meet,
+// along with how many were checked and how many did not meet it.
+type sloRule struct {
+	comparison
+	checked  int
+	violated int
+}
+
+var sloRules []*sloRule
+
+// setSLOs parses the -slo objectives.
+func setSLOs() error {
+	sloRules = nil
+	for _, s := range sloSpecs {
+		c, err := parseComparison("slo", s)
+		if err != nil {
+			return err
+		}
+		sloRules = append(sloRules, &sloRule{comparison: c})
+	}
+	return nil
+}
+
+// check returns whether the entry has the field of the objective and whether
+// it violates it.  a field that is not a number is not checked against a
+// numeric objective.
+func (r *sloRule) check(e *Entry) (checked, violated bool) {
+	field := valueRule{key: r.key}
+	val, ok := field.value(e)
+	if !ok {
+		return false, false
+	}
+	if r.re == nil && r.op != "=" && r.op != "!=" {
+		if _, ok := parseNumber(val); !ok {
+			return false, false
+		}
+	}
+	return true, !r.compare(val)
+}
+
+// countSLOs counts the entry for the objectives it has the field of.
+func countSLOs(e *Entry) {
+	for _, r := range sloRules {
+		checked, violated := r.check(e)
+		if checked {
+			r.checked++
+		}
+		if violated {
+			r.violated++
+		}
+	}
+}
+
+// appendSLOs marks the objectives the entry violates.
+func appendSLOs(b []byte, e *Entry) []byte {
+	for _, r := range sloRules {
+		if _, violated := r.check(e); violated {
+			b = append(b, ' ')
+			b = append(b, highlightColor...)
+			b = append(b, "[slo "...)
+			b = append(b, r.String()...)
+			b = append(b, ']')
+			b = append(b, colorReset...)
+		}
+	}
+	return b
+}
+
+// printSLOs prints the share of the checked entries that violated each
+// objective.
+func printSLOs(w io.Writer) {
+	if len(sloRules) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%sobjectives%s\n", tagColor, colorReset)
+	for _, r := range sloRules {
+		pct := 0.0
+		if r.checked > 0 {
+			pct = 100 * float64(r.violated) / float64(r.checked)
+		}
+		clr := infoColor
+		if r.violated > 0 {
+			clr = warnColor
+		}
+		fmt.Fprintf(w, "%8d %s%5.1f%%%s of %d violated %s\n", r.violated, clr, pct, colorReset, r.checked, r.String())
+	}
+}
@@ -0,0 +1,523 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// how often the checkpoint file is written.
+const saveInterval = time.Second
+
+// Source is a stream of log lines, such as a file, a followed file or stdin.
+type Source interface {
+	// Open prepares the source for reading.
+	Open() error
+	// Next returns the next line, or io.EOF when there are no more lines.
+	// the data is only valid until the next call to Next.
+	Next() (logLine, error)
+	// Close releases the resources used by the source.
+	Close() error
+	// Label identifies where the lines came from.
+	Label() string
+}
+
+// a line read from a source.
+type logLine struct {
+	data    []byte  // the line without the trailing newline.
+	notice  string  // if set, a notice to display instead of a line.
+	dropped int     // if set, the number of lines dropped by -drop instead of a line.
+	cur     *cursor // the position just past the line, for sources that can resume.
+	file    string  // if set, the file of a source of several files it was read from.
+}
+
+// label returns the label of the line, which is that of its source unless it
+// was read from one of several files.
+func (l *logLine) label(s Source) string {
+	if l.file != "" {
+		return l.file
+	}
+	return s.Label()
+}
+
+// sourcedLine is a line along with the label of the source it was read from.
+type sourcedLine struct {
+	src string
+	logLine
+}
+
+// creates a source of each type from the rest of its spec.
+var sourceTypes = make(map[string]func(arg string) Source)
+
+// registers a source type that can be selected by prefixing a command line
+// argument with its name, like tail:/path/to/file.log.
+func registerSource(name string, fn func(arg string) Source) {
+	sourceTypes[name] = fn
+}
+
+func init() {
+	registerSource("file", func(arg string) Source { return &fileSource{path: arg} })
+	registerSource("stdin", func(string) Source { return &stdinSource{} })
+	registerSource("tail", func(arg string) Source { return &tailSource{path: arg} })
+}
+
+// the checkpoint of the followed files if -checkpoint is set.
+var cp *checkpoint
+
+// newSource returns the source described by a command line argument.  '-' is
+// stdin, 'name:arg' is a registered source type and anything else is a file
+// that is read, or followed if -tail is set.
+func newSource(spec string) Source {
+	if spec == "-" {
+		return sourceTypes["stdin"]("")
+	}
+	if name, arg, ok := strings.Cut(spec, ":"); ok {
+		if fn, ok := sourceTypes[name]; ok {
+			return fn(arg)
+		}
+	}
+	if *tailFile {
+		if isGlob(spec) {
+			return newGlobSource(spec)
+		}
+		return sourceTypes["tail"](spec)
+	}
+	return sourceTypes["file"](spec)
+}
+
+// displays a line read from the source.
+func show(src string, l logLine) {
+	if l.dropped > 0 {
+		printNotice(fmt.Sprintf("%d lines dropped from %s", l.dropped, src))
+	} else if l.notice != "" {
+		printNotice(l.notice)
+	} else {
+		writeTee(l.data)
+		reformat(src, l.data)
+	}
+	if cp != nil && l.cur != nil {
+		cp.set(src, *l.cur)
+	}
+	if flushEach {
+		out.Flush()
+		flushTees()
+	}
+}
+
+// cat reads each source to the end, one after the other.
+func cat(srcs []Source) error {
+	fn := func(s Source) error {
+		if *headLines > 0 {
+			return catHead(s, *headLines)
+		}
+		if lastLimit.isSet() {
+			return catLast(s, lastLimit)
+		}
+		if err := s.Open(); err != nil {
+			return err
+		}
+		defer s.Close()
+
+		// loop until EOF.
+		for {
+			l, err := s.Next()
+			if err == io.EOF {
+				flushCRI(s.Label())
+				flushDoc(s.Label(), reformatLine)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			show(s.Label(), l)
+			if err := pagerError(); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, s := range srcs {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tail reads all of the sources at the same time and displays lines as they
+// arrive until every source has ended or glogv is interrupted.
+func tail(srcs []Source) error {
+	if *cpFile != "" {
+		var err error
+		if cp, err = loadCheckpoint(*cpFile); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range srcs {
+		if err := s.Open(); err != nil {
+			return err
+		}
+	}
+
+	lines := make(chan sourcedLine, 64)
+	errs := make(chan error, len(srcs))
+	drops := make([]*dropper, len(srcs))
+	for i, s := range srcs {
+		drops[i] = &dropper{src: s.Label()}
+		go func(s Source, d *dropper) {
+			defer s.Close()
+			for {
+				l, err := s.Next()
+				if err != nil {
+					d.finish(lines)
+					if err == io.EOF {
+						err = nil
+					}
+					errs <- err
+					return
+				}
+				l.data = bytes.Clone(l.data)
+				if *dropLines {
+					d.send(lines, l)
+				} else {
+					lines <- sourcedLine{src: l.label(s), logLine: l}
+				}
+			}
+		}(s, drops[i])
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	// the config file is reloaded on SIGHUP or when it changes.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	watch := newConfigWatcher()
+
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+
+	// the lines written to the output go to the -tui instead.  filter
+	// commands are typed in the -tui or on the terminal with -commands.
+	var quit <-chan struct{}
+	var cmds <-chan string
+	if *tuiOn {
+		t, err := newTUI()
+		if err != nil {
+			return err
+		}
+		tui, quit, cmds = t, t.quit, t.commands
+		defer t.close()
+		if *splitView {
+			labels := make([]string, len(srcs))
+			for i, s := range srcs {
+				labels[i] = s.Label()
+			}
+			t.split(labels)
+		}
+		out = bufio.NewWriter(&tuiWriter{})
+	} else if *filterCommands {
+		var err error
+		if cmds, err = readCommands(); err != nil {
+			return err
+		}
+	}
+
+	// the panel is redrawn on its own ticker, which is never ready without one.
+	// in the -tui it is a pane on the right, so the tui is started first.
+	var redraw <-chan time.Time
+	if *panelOn || *statusOn {
+		var status []Source
+		if *statusOn {
+			status = srcs
+		}
+		if pnl = newPanel(*panelOn, *panelKey, *panelWindow, status); pnl != nil {
+			defer pnl.close()
+			t := time.NewTicker(panelInterval)
+			defer t.Stop()
+			redraw = t.C
+		}
+	}
+
+	var rec *recorder
+	if *recordFile != "" {
+		var err error
+		if rec, err = newRecorder(*recordFile, srcs); err != nil {
+			return err
+		}
+		defer rec.close()
+	}
+
+	display := func(src string, l logLine) {
+		if pnl != nil {
+			pnl.read(src, l)
+		}
+		if rec != nil {
+			rec.write(src, l)
+		}
+		show(src, l)
+	}
+
+	active := len(srcs)
+	for {
+		select {
+		case l := <-lines:
+			if l.file != "" {
+				addLabel(l.file)
+			}
+			display(l.src, l.logLine)
+		case <-redraw:
+			pnl.draw()
+		case <-hup:
+			showReload()
+			out.Flush()
+		case cmd := <-cmds:
+			runCommand(cmd)
+			out.Flush()
+		case <-ticker.C:
+			if watch.changed() {
+				showReload()
+				out.Flush()
+			}
+
+			// show the lines dropped since the output caught up.
+			for _, d := range drops {
+				d.report(lines, display)
+			}
+			if *rollupEvery > 0 {
+				rollupTick(time.Now())
+				out.Flush()
+			}
+			if *dedup {
+				dedupTick()
+				out.Flush()
+			}
+			if rec != nil {
+				if err := rec.flush(); err != nil {
+					return err
+				}
+			}
+			if cp != nil {
+				if err := cp.save(); err != nil {
+					return err
+				}
+			}
+		case <-sigs:
+			if cp != nil {
+				return cp.save()
+			}
+			return nil
+		case <-quit:
+			if cp != nil {
+				return cp.save()
+			}
+			return nil
+		case err := <-errs:
+			// show whatever the source sent before it stopped.
+			for len(lines) > 0 {
+				l := <-lines
+				display(l.src, l.logLine)
+			}
+
+			// keep going while other sources are still being read.
+			active--
+			if err == nil && active > 0 {
+				continue
+			}
+
+			if cp != nil {
+				if saveErr := cp.save(); saveErr != nil && err == nil {
+					err = saveErr
+				}
+			}
+
+			// the lines can still be browsed once every source has ended.
+			if tui != nil && err == nil {
+				flushDedup()
+				out.Flush()
+				select {
+				case <-quit:
+				case <-sigs:
+				}
+			}
+			return err
+		}
+	}
+}
+
+// lineScanner implements Next for sources that are read a line at a time.  a
+// source that is a single json array is streamed one element at a time
+// instead.
+type lineScanner struct {
+	rd    *bufio.Reader
+	buf   []byte
+	array *json.Decoder
+	elem  json.RawMessage
+}
+
+// how far into a source to look for the start of a json array.
+const arrayPeek = 4096
+
+// open reads lines from br, or the elements of a json array if that is what
+// it starts with.
+func (s *lineScanner) open(br *bufio.Reader) error {
+	for i := 1; i <= arrayPeek; i++ {
+		b, _ := br.Peek(i)
+		if len(b) < i {
+			break
+		}
+		c := b[i-1]
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		if c == '[' {
+			s.array = json.NewDecoder(br)
+			_, err := s.array.Token()
+			return err
+		}
+		break
+	}
+
+	s.rd = br
+	return nil
+}
+
+// Next returns the next line, a line longer than -max-line-size is read to
+// its end and replaced by a notice.
+func (s *lineScanner) Next() (logLine, error) {
+	if s.array != nil {
+		return s.nextElem()
+	}
+
+	s.buf = s.buf[:0]
+	var skipped int64
+	skipping := false
+	for {
+		b, err := s.rd.ReadSlice('\n')
+		if skipping {
+			skipped += int64(len(b))
+		} else if len(s.buf)+len(b) > int(maxLineSize) {
+			skipping = true
+			skipped = int64(len(s.buf) + len(b))
+			s.buf = s.buf[:0]
+		} else {
+			s.buf = append(s.buf, b...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return logLine{}, err
+		}
+		if skipping {
+			return longLine(skipped), nil
+		}
+		if len(s.buf) == 0 && err == io.EOF {
+			return logLine{}, io.EOF
+		}
+		return logLine{data: trimNewline(s.buf)}, nil
+	}
+}
+
+// nextElem returns the next element of a json array.
+func (s *lineScanner) nextElem() (logLine, error) {
+	if !s.array.More() {
+		return logLine{}, io.EOF
+	}
+	s.elem = s.elem[:0]
+	if err := s.array.Decode(&s.elem); err != nil {
+		return logLine{}, err
+	}
+	return logLine{data: s.elem}, nil
+}
+
+// stdinSource reads lines from stdin, which may be compressed.
+type stdinSource struct {
+	lineScanner
+	dec io.ReadCloser
+}
+
+func (s *stdinSource) Open() error {
+	br, dec, err := decompress("stdin", bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+	s.dec = dec
+	return s.open(br)
+}
+
+func (s *stdinSource) Close() error {
+	if s.dec != nil {
+		return s.dec.Close()
+	}
+	return nil
+}
+
+func (s *stdinSource) Label() string { return "stdin" }
+
+// fileSource reads lines from a file, which may be compressed.
+type fileSource struct {
+	lineScanner
+	path string
+	file *os.File
+	dec  io.ReadCloser
+
+	// last is -last, a regular file that is not compressed is read from the
+	// start of what it shows and seeked is set.
+	last   lastFlag
+	seeked bool
+}
+
+func (s *fileSource) Open() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	br, dec, err := decompress(s.path, bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.dec = dec
+	if s.last.isSet() && dec == nil {
+		if s.seeked, err = seekLast(file, s.path, s.last); err != nil {
+			file.Close()
+			return err
+		}
+		if s.seeked {
+			br.Reset(file)
+		}
+	}
+	if err := s.open(br); err != nil {
+		if s.dec != nil {
+			s.dec.Close()
+		}
+		file.Close()
+		return err
+	}
+	s.file = file
+
+	return nil
+}
+
+func (s *fileSource) Close() error {
+	var err error
+	if s.dec != nil {
+		err = s.dec.Close()
+	}
+	return errors.Join(err, s.file.Close())
+}
+
+func (s *fileSource) Label() string { return s.path }
@@ -0,0 +1,138 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sparkSlots is the number of characters of the sparkline of each bucket.
+const sparkSlots = 20
+
+// the characters of a sparkline from the lowest to the highest value.
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkBucket holds the values of a field within one -bucket of time.
+type sparkBucket struct {
+	start time.Time
+	count int
+	sum   float64
+	max   float64
+	slots [sparkSlots]struct {
+		sum   float64
+		count int
+	}
+}
+
+// sparklines collects the values of a numeric field by time for the
+// -sparkline of the stats subcommand.
+type sparklines struct {
+	key     string
+	every   time.Duration
+	buckets map[int64]*sparkBucket
+	skipped int
+}
+
+func newSparklines(key string, every time.Duration) *sparklines {
+	return &sparklines{key: key, every: every, buckets: make(map[int64]*sparkBucket)}
+}
+
+// add adds the value of the field of the entry.  entries without a time or
+// a numeric value are counted as skipped.
+func (s *sparklines) add(e *Entry) {
+	v, ok := e.Fields[s.key]
+	if !ok {
+		return
+	}
+	f, ok := sparkValue(v)
+	if !ok || e.Time.IsZero() {
+		s.skipped++
+		return
+	}
+
+	start := e.Time.Truncate(s.every)
+	b, ok := s.buckets[start.UnixNano()]
+	if !ok {
+		b = &sparkBucket{start: start, max: f}
+		s.buckets[start.UnixNano()] = b
+	}
+	b.count++
+	b.sum += f
+	b.max = math.Max(b.max, f)
+
+	slot := int(e.Time.Sub(start) * sparkSlots / s.every)
+	b.slots[slot].sum += f
+	b.slots[slot].count++
+}
+
+// sparkValue returns a number, a numeric string or a duration like "32ms" in
+// milliseconds as a float.
+func sparkValue(v any) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f, true
+		}
+		if d, err := time.ParseDuration(val); err == nil {
+			return float64(d) / float64(time.Millisecond), true
+		}
+	}
+	return 0, false
+}
+
+// print prints a line per bucket with its count, average and maximum and the
+// sparkline of the averages within it.  every sparkline has the same scale
+// so they can be compared.
+func (s *sparklines) print() {
+	buckets := make([]*sparkBucket, 0, len(s.buckets))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, b := range s.buckets {
+		buckets = append(buckets, b)
+		for _, slot := range b.slots {
+			if slot.count > 0 {
+				avg := slot.sum / float64(slot.count)
+				lo, hi = math.Min(lo, avg), math.Max(hi, avg)
+			}
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].start.Before(buckets[j].start) })
+
+	fmt.Printf("\n%s%s per %v%s\n", tagColor, s.key, s.every, colorReset)
+	for _, b := range buckets {
+		var sb strings.Builder
+		for _, slot := range b.slots {
+			if slot.count == 0 {
+				sb.WriteRune(' ')
+				continue
+			}
+			sb.WriteRune(sparkChar(slot.sum/float64(slot.count), lo, hi))
+		}
+		ts := string(appendDisplayTime(nil, b.start))
+		avg := b.sum / float64(b.count)
+		fmt.Printf("%s%s%s %s%s%s %8d avg %-10s max %s\n", timeColor, ts, colorReset,
+			infoColor, sb.String(), colorReset, b.count, formatSpark(avg), formatSpark(b.max))
+	}
+	if s.skipped > 0 {
+		fmt.Printf("%s%d entries without a time or a number%s\n", tagColor, s.skipped, colorReset)
+	}
+}
+
+// sparkChar returns the character of v between lo and hi.
+func sparkChar(v, lo, hi float64) rune {
+	if hi <= lo {
+		return sparkChars[0]
+	}
+	i := int((v - lo) / (hi - lo) * float64(len(sparkChars)-1))
+	return sparkChars[max(0, min(i, len(sparkChars)-1))]
+}
+
+// formatSpark formats a value with at most two decimals.
+func formatSpark(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
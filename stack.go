@@ -0,0 +1,108 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var stacks = flag.Bool("stacks", true, "show stack traces as an indented block under the entry")
+
+// the fields that hold a stack trace, either the frames written by zerolog's
+// pkg/errors marshaler or a go panic trace as a string.
+var stackKeys = map[string]bool{
+	"stack":       true,
+	"stacktrace":  true,
+	"stack_trace": true,
+}
+
+// fileLine finds the file:line parts of a trace, like /src/main.go:42.
+var fileLine = regexp.MustCompile(`[\w./\\@-]+\.\w+:\d+`)
+
+// isStack returns true if a field is shown as a stack trace instead of in the
+// key/value section.
+func isStack(key string, v any) bool {
+	if !*stacks || !stackKeys[key] {
+		return false
+	}
+	switch val := v.(type) {
+	case string:
+		return strings.Contains(val, "\n")
+	case []any:
+		return len(val) > 0
+	}
+	return false
+}
+
+// appendStacks appends the stack traces of the entry as a dimmed block on the
+// lines after the entry, with the file:line of each frame highlighted.  the
+// fields are looked up by name as most entries have none of them.
+func appendStacks(b []byte, e *Entry) []byte {
+	var names []string
+	for k := range stackKeys {
+		if v, ok := e.Fields[k]; ok && isStack(k, v) && showKey(k) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	clr := getColor(e.Level)
+	for _, k := range names {
+		v := e.Fields[k]
+		b = append(b, colorReset...)
+		b = append(b, '\n')
+		b = append(b, expandIndent...)
+		b = append(b, tagColor...)
+		b = append(b, k...)
+		b = append(b, ':')
+
+		if s, ok := v.(string); ok {
+			for _, l := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+				b = appendFrame(b, strings.TrimSpace(l), clr)
+			}
+			continue
+		}
+		frames, _ := v.([]any)
+		for _, f := range frames {
+			frame, ok := f.(map[string]any)
+			if !ok {
+				b = appendFrame(b, string(appendValue(nil, f)), clr)
+				continue
+			}
+			b = appendFrame(b, frameText(frame), clr)
+		}
+	}
+	return b
+}
+
+// frameText returns a frame written by zerolog as func source:line.
+func frameText(frame map[string]any) string {
+	fn, _ := frame["func"].(string)
+	src, _ := frame["source"].(string)
+	line := string(appendValue(nil, frame["line"]))
+	if src == "" {
+		return fn
+	}
+	return fn + " " + src + ":" + line
+}
+
+// appendFrame appends a line of a stack trace, dimmed except for the file:line.
+func appendFrame(b []byte, s string, clr string) []byte {
+	b = append(b, colorReset...)
+	b = append(b, '\n')
+	b = append(b, expandIndent...)
+	b = append(b, expandIndent...)
+	b = append(b, dimColor...)
+	for _, loc := range fileLine.FindAllStringIndex(s, -1) {
+		b = append(b, s[:loc[0]]...)
+		b = append(b, colorReset...)
+		b = append(b, clr...)
+		b = append(b, s[loc[0]:loc[1]]...)
+		b = append(b, colorReset...)
+		b = append(b, dimColor...)
+		s = s[loc[1]:]
+	}
+	return append(b, s...)
+}
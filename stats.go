@@ -0,0 +1,224 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+// wildcard replaces the variable tokens of a message template.
+const wildcard = "<*>"
+
+// minSimilarity is the fraction of tokens two messages of the same length
+// must share to be clustered into one template.
+const minSimilarity = 0.5
+
+// template is a message with its variable tokens replaced by wildcards.
+type template struct {
+	tokens []string
+	count  int
+}
+
+// clusters groups messages into templates in the manner of the drain log
+// parser, messages are only compared to templates with as many tokens.
+type clusters struct {
+	byLen     map[int][]*template
+	templates int
+	total     int
+}
+
+func newClusters() *clusters {
+	return &clusters{byLen: make(map[int][]*template)}
+}
+
+// add adds a message to the template that is the most similar to it or
+// starts a new template.
+func (c *clusters) add(msg string) {
+	tokens := strings.Fields(msg)
+	for i, tok := range tokens {
+		tokens[i] = maskToken(tok)
+	}
+	c.total++
+
+	var best *template
+	bestSim := minSimilarity
+	for _, t := range c.byLen[len(tokens)] {
+		if sim := similarity(t.tokens, tokens); sim >= bestSim {
+			best, bestSim = t, sim
+		}
+	}
+
+	if best == nil {
+		c.byLen[len(tokens)] = append(c.byLen[len(tokens)], &template{tokens: tokens, count: 1})
+		c.templates++
+		return
+	}
+	for i, tok := range tokens {
+		if best.tokens[i] != tok {
+			best.tokens[i] = wildcard
+		}
+	}
+	best.count++
+}
+
+// top returns the n templates seen the most.
+func (c *clusters) top(n int) []*template {
+	var all []*template
+	for _, ts := range c.byLen {
+		all = append(all, ts...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].count != all[j].count {
+			return all[i].count > all[j].count
+		}
+		return strings.Join(all[i].tokens, " ") < strings.Join(all[j].tokens, " ")
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// similarity returns the fraction of tokens that are the same, wildcards of
+// the template match anything.
+func similarity(tmpl, tokens []string) float64 {
+	if len(tokens) == 0 {
+		return 1
+	}
+	same := 0
+	for i, tok := range tokens {
+		if tmpl[i] == tok || tmpl[i] == wildcard {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+// maskToken replaces a token containing a digit, which covers numbers, ids,
+// uuids, ip addresses and timestamps, with a wildcard.  the key of a
+// key=value token is kept.
+func maskToken(tok string) string {
+	key, val, ok := strings.Cut(tok, "=")
+	if !ok {
+		key, val = "", tok
+	}
+	if !strings.ContainsAny(val, "0123456789") {
+		return tok
+	}
+	if ok {
+		return key + "=" + wildcard
+	}
+	return wildcard
+}
+
+// runStats implements the stats subcommand, which summarizes the entries of
+// the sources instead of displaying them.
+func runStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	cluster := fs.Bool("cluster", false, "report the most common message templates")
+	top := fs.Int("top", 20, "number of message templates to report")
+	spark := fs.String("sparkline", "", "report the trend of the given numeric field over time")
+	by := fs.String("by", "", "report the top values of the given field")
+	bucket := fs.Duration("bucket", time.Minute, "the length of time of each line of the -sparkline")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	srcs, err := parseSources(files)
+	if err != nil {
+		return err
+	}
+
+	if *bucket <= 0 {
+		return fmt.Errorf("-bucket must be positive")
+	}
+
+	summ := newSummary(*by)
+	c := newClusters()
+	var sl *sparklines
+	if *spark != "" {
+		sl = newSparklines(*spark, *bucket)
+	}
+	e := Entry{Fields: make(map[string]any)}
+	fn := func(s Source) error {
+		if err := s.Open(); err != nil {
+			return err
+		}
+		defer s.Close()
+
+		for {
+			l, err := s.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if l.notice != "" || !parseEntry(s.Label(), l.data, &e) {
+				continue
+			}
+			summ.add(&e)
+			if *cluster {
+				c.add(e.Message)
+			}
+			if sl != nil {
+				sl.add(&e)
+			}
+		}
+	}
+
+	for _, s := range srcs {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	summ.print(os.Stdout)
+	if *cluster {
+		printTemplates(c, *top)
+	}
+	if sl != nil {
+		sl.print()
+	}
+
+	return nil
+}
+
+func printLevelStats(w io.Writer, levels map[string]int) {
+	total := 0
+	names := make([]string, 0, len(levels))
+	for name, n := range levels {
+		names = append(names, name)
+		total += n
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if levels[names[i]] != levels[names[j]] {
+			return levels[names[i]] > levels[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	fmt.Fprintf(w, "%s%d entries%s\n", tagColor, total, colorReset)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s%s%s %8d\n", getColor(name), format.LevelLabel(name), colorReset, levels[name])
+	}
+}
+
+func printTemplates(c *clusters, n int) {
+	fmt.Printf("\n%s%d templates from %d messages%s\n", tagColor, c.templates, c.total, colorReset)
+	for _, t := range c.top(n) {
+		pct := 100 * float64(t.count) / float64(c.total)
+		fmt.Printf("%8d %5.1f%% %s\n", t.count, pct, strings.Join(t.tokens, " "))
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	statsOn = flag.Bool("stats", false, "report the counts per level, the top errors and the rate of entries after the output")
+	statsBy = flag.String("stats-by", "", "also report the top values of the given field with -stats")
+)
+
+const (
+	summaryTop    = 10     // number of top errors and values reported.
+	summaryValues = 100000 // number of distinct errors and values counted.
+	summaryRates  = 20     // most lines of the rate of entries.
+	summaryBar    = 40     // width of the bar of the highest rate.
+)
+
+// the nice lengths of time the rate of entries is reported by.
+var summaryBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// summary collects the counts reported by -stats and the stats subcommand.
+type summary struct {
+	levels  map[string]int
+	errors  map[string]int // masked messages of the error, fatal and panic entries.
+	by      string
+	values  map[string]int // values of the by field.
+	minutes map[int64]int  // entries by the minute of their time.
+}
+
+// the summary of the shown entries when -stats is set.
+var summ *summary
+
+func newSummary(by string) *summary {
+	return &summary{
+		levels:  make(map[string]int),
+		errors:  make(map[string]int),
+		by:      by,
+		values:  make(map[string]int),
+		minutes: make(map[int64]int),
+	}
+}
+
+func (s *summary) add(e *Entry) {
+	s.levels[e.Level]++
+	countSLOs(e)
+	countExpects(e)
+
+	if e.Level == "error" || e.Level == "fatal" || e.Level == "panic" {
+		msg := e.Message
+		if msg == "" {
+			msg = e.Error
+		}
+		// numbers and ids are masked so the same error counts once.
+		tokens := strings.Fields(msg)
+		for i, tok := range tokens {
+			tokens[i] = maskToken(tok)
+		}
+		countValue(s.errors, strings.Join(tokens, " "))
+	}
+	if s.by != "" {
+		r := valueRule{key: s.by}
+		if v, ok := r.value(e); ok {
+			countValue(s.values, v)
+		}
+	}
+	if !e.Time.IsZero() {
+		s.minutes[e.Time.Unix()/60]++
+	}
+}
+
+// countValue counts the value, new values are not counted once there are
+// too many different ones.
+func countValue(m map[string]int, v string) {
+	if _, ok := m[v]; ok || len(m) < summaryValues {
+		m[v]++
+	}
+}
+
+func (s *summary) print(w io.Writer) {
+	printLevelStats(w, s.levels)
+	if len(s.errors) > 0 {
+		printTop(w, "top errors", s.errors)
+	}
+	if s.by != "" {
+		printTop(w, "top "+s.by, s.values)
+	}
+	if len(s.minutes) > 0 {
+		s.printRates(w)
+	}
+	printSLOs(w)
+	printExpects(w)
+}
+
+// printTop prints the most common of the counted values.
+func printTop(w io.Writer, title string, m map[string]int) {
+	total := 0
+	vals := make([]string, 0, len(m))
+	for v, n := range m {
+		vals = append(vals, v)
+		total += n
+	}
+	sort.Slice(vals, func(i, j int) bool {
+		if m[vals[i]] != m[vals[j]] {
+			return m[vals[i]] > m[vals[j]]
+		}
+		return vals[i] < vals[j]
+	})
+
+	fmt.Fprintf(w, "\n%s%s of %d%s\n", tagColor, title, total, colorReset)
+	for _, v := range vals[:min(len(vals), summaryTop)] {
+		pct := 100 * float64(m[v]) / float64(total)
+		fmt.Fprintf(w, "%8d %5.1f%% %s\n", m[v], pct, v)
+	}
+}
+
+// rateDate returns the date of a time in the zone times are displayed in.
+func rateDate(sec int64) string {
+	t := time.Unix(sec, 0)
+	if timeZone != nil {
+		t = t.In(timeZone)
+	}
+	return t.Format(time.DateOnly)
+}
+
+// rateLabel returns the start of a row of the rates, as only a date for
+// buckets of days, and with the date in front of the time when the rows span
+// several days and the -time-format has no date.
+func rateLabel(start int64, bucket time.Duration, days bool) string {
+	switch {
+	case bucket >= 24*time.Hour:
+		return rateDate(start)
+	case days && timeFormat != unixTime && !strings.Contains(timeFormat, "2006"):
+		return rateDate(start) + " " + string(appendDisplayTime(nil, time.Unix(start, 0)))
+	}
+	return string(appendDisplayTime(nil, time.Unix(start, 0)))
+}
+
+// printRates prints the entries per second over time, in buckets picked so
+// there are no more than summaryRates lines.
+func (s *summary) printRates(w io.Writer) {
+	first, last := int64(1<<62), int64(-1<<62)
+	for m := range s.minutes {
+		first, last = min(first, m), max(last, m)
+	}
+	span := time.Duration(last-first+1) * time.Minute
+	// a span longer than the buckets is split into whole days.
+	day := summaryBuckets[len(summaryBuckets)-1]
+	bucket := (span/summaryRates + day - 1) / day * day
+	for _, b := range summaryBuckets {
+		if span <= b*summaryRates {
+			bucket = b
+			break
+		}
+	}
+
+	counts := make(map[int64]int)
+	peak := 0
+	for m, n := range s.minutes {
+		start := time.Unix(m*60, 0).Truncate(bucket).Unix()
+		counts[start] += n
+		peak = max(peak, counts[start])
+	}
+	starts := make([]int64, 0, len(counts))
+	for start := time.Unix(first*60, 0).Truncate(bucket).Unix(); start <= last*60; start += int64(bucket.Seconds()) {
+		starts = append(starts, start)
+	}
+
+	// the rows of more than one day are told apart by their date.
+	days := bucket >= 24*time.Hour || rateDate(first*60) != rateDate(last*60)
+
+	fmt.Fprintf(w, "\n%sentries per second by %v%s\n", tagColor, bucket, colorReset)
+	for _, start := range starts {
+		n := counts[start]
+		rate := float64(n) / bucket.Seconds()
+		bar := strings.Repeat("█", (n*summaryBar+peak-1)/peak)
+		ts := rateLabel(start, bucket, days)
+		fmt.Fprintf(w, "%s%s%s %10s %s%s%s\n", timeColor, ts, colorReset, formatSpark(rate), infoColor, bar, colorReset)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	// RFC 5424: <pri>1 timestamp host app procid msgid structured-data msg
+	syslog5424 = regexp.MustCompile(`^<(\d{1,3})>1 (\S+) (\S+) (\S+) (\S+) (\S+) (-|(?:\[(?:[^\]\\]|\\.)*\])+) ?(.*)$`)
+	// RFC 3164: <pri>Mmm dd hh:mm:ss host app[pid]: msg, the pri is optional
+	// in files written by syslog daemons.  the month may be in another
+	// language with -time-locale.
+	syslog3164 = regexp.MustCompile(`^(?:<(\d{1,3})>)?(\p{L}{3,5}\.? [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) ([^:\[\s]+)(?:\[(\d+)\])?: ?(.*)$`)
+)
+
+// syslog severities, the lowest three bits of the priority.
+var syslogLevels = []string{"fatal", "fatal", "fatal", "error", "warn", "info", "info", "debug"}
+
+// returns true if the line looks like syslog.
+func isSyslog(b []byte) bool {
+	return syslog5424.Match(b) || syslog3164.Match(b)
+}
+
+// parseSyslog parses an RFC 5424 or RFC 3164 syslog line.
+func parseSyslog(e *Entry, b []byte) error {
+	clear(e.Fields)
+
+	if m := syslog5424.FindSubmatch(b); m != nil {
+		setSyslogLevel(e, m[1])
+		setTextField(e, "host", m[3])
+		setTextField(e, "app", m[4])
+		setTextField(e, "pid", m[5])
+		setTextField(e, "msgid", m[6])
+		setTextField(e, "data", m[7])
+		e.Fields["message"] = string(m[8])
+		extract(e, &stdKeys)
+		e.Time, _ = time.Parse(time.RFC3339, string(m[2]))
+		return nil
+	}
+
+	if m := syslog3164.FindSubmatch(b); m != nil {
+		setSyslogLevel(e, m[1])
+		setTextField(e, "host", m[3])
+		setTextField(e, "app", m[4])
+		setTextField(e, "pid", m[5])
+		e.Fields["message"] = string(m[6])
+		extract(e, &stdKeys)
+
+		// RFC 3164 timestamps do not have a year, so assume the current one.
+		if tm, err := time.ParseInLocation(time.Stamp, localizeTime(string(m[2])), time.Local); err == nil {
+			e.Time = tm.AddDate(time.Now().Year(), 0, 0)
+		}
+		return nil
+	}
+
+	return errNoMatch
+}
+
+// sets the level from the syslog priority, info if there is none.
+func setSyslogLevel(e *Entry, pri []byte) {
+	e.Fields["level"] = "info"
+	if n, err := strconv.Atoi(string(pri)); err == nil {
+		e.Fields["level"] = syslogLevels[n%8]
+	}
+}
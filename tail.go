@@ -0,0 +1,398 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	pollInterval = 250 * time.Millisecond // how often followed files are checked for new data.
+	tailLines    = 10                     // lines displayed from the end of a file when following starts.
+)
+
+// follower follows a single file by name, reopening it when it is rotated.
+type follower struct {
+	path    string
+	file    *os.File
+	id      fileID
+	offset  int64
+	rd      *bufio.Reader
+	pending []byte
+	skipped int64 // the length of a line longer than -max-line-size.
+}
+
+// tailSource follows a file by name, like tail --follow=name.
+type tailSource struct {
+	path  string
+	lines chan logLine
+	errs  chan error
+}
+
+func (s *tailSource) Open() error {
+	// check if the file exists first, unless we are going to wait for it.
+	// inherited file descriptors like /dev/fd/63 are opened directly.
+	if !*retry && !isFD(s.path) {
+		if _, err := os.Stat(s.path); err != nil {
+			return err
+		}
+	}
+
+	var cur *cursor
+	if cp != nil {
+		cur = cp.get(s.path)
+	}
+
+	s.lines = make(chan logLine)
+	s.errs = make(chan error, 1)
+	f := &follower{path: s.path}
+	go func() {
+		s.errs <- f.follow(cur, s.lines)
+	}()
+
+	return nil
+}
+
+func (s *tailSource) Next() (logLine, error) {
+	select {
+	case l := <-s.lines:
+		return l, nil
+	case err := <-s.errs:
+		if err == nil {
+			err = io.EOF
+		}
+		return logLine{}, err
+	}
+}
+
+// Close does nothing, the follower keeps running until glogv exits.
+func (s *tailSource) Close() error { return nil }
+
+func (s *tailSource) Label() string { return s.path }
+
+// follow positions the follower at the point it should start reading from
+// then sends new lines to the channel as they are written.  cur is the
+// position saved in the checkpoint file, if any.
+func (f *follower) follow(cur *cursor, lines chan<- logLine) error {
+	fi, err := f.open()
+	if errors.Is(err, fs.ErrNotExist) && *retry {
+		// the file did not exist yet, so everything in it is new.
+		lines <- logLine{notice: f.path + " does not exist yet, waiting for it"}
+		if fi, err = f.waitForFile(); err != nil {
+			return err
+		}
+		lines <- logLine{notice: f.path + " created", cur: &cursor{fileID: f.id}}
+		if isPipe(fi) {
+			return f.pipe(lines)
+		}
+		return f.poll(lines)
+	}
+	if err != nil {
+		return err
+	}
+
+	// named pipes can not be seeked or rotated.
+	if isPipe(fi) {
+		return f.pipe(lines)
+	}
+
+	switch {
+	case cur == nil:
+		// start with the last few lines of the file like tail does.
+		off, err := lastLinesOffset(f.file, fi.Size(), tailLines)
+		if err != nil {
+			return err
+		}
+		if err := f.seek(off); err != nil {
+			return err
+		}
+	case cur.fileID == f.id:
+		// same file as last time, resume where we left off unless the file
+		// has since been truncated.
+		if cur.Offset <= fi.Size() {
+			if err := f.seek(cur.Offset); err != nil {
+				return err
+			}
+		}
+	default:
+		// the file was rotated while we were not running, so finish reading
+		// the rotated file before starting the new one from the beginning.
+		if old := findRotated(f.path, cur.fileID); old != "" {
+			if err := f.drainRotated(old, cur.Offset, lines); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.poll(lines)
+}
+
+// poll reads lines from the followed file until an error occurs.
+func (f *follower) poll(lines chan<- logLine) error {
+	for {
+		err := f.read(lines)
+		if err != io.EOF {
+			return err
+		}
+
+		time.Sleep(pollInterval)
+
+		// check if the file has been rotated.
+		fi, err := os.Stat(f.path)
+		if err != nil {
+			// the file may be in the middle of being rotated, check again later.
+			continue
+		}
+		if getFileID(f.path, fi) == f.id {
+			// a file that shrinks was truncated in place, start over from
+			// the beginning of it.
+			if fi.Size() < f.offset+f.skipped+int64(len(f.pending)) {
+				if err := f.seek(0); err != nil {
+					return err
+				}
+				f.pending, f.skipped = f.pending[:0], 0
+				lines <- logLine{notice: f.path + " truncated", cur: &cursor{fileID: f.id}}
+			}
+			continue
+		}
+
+		// finish reading whatever was written to the old file, then switch.
+		if err := f.read(lines); err != nil && err != io.EOF {
+			return err
+		}
+		f.flush(lines)
+		f.file.Close()
+		if _, err := f.waitForFile(); err != nil {
+			return err
+		}
+		lines <- logLine{notice: f.path + " replaced", cur: &cursor{fileID: f.id}}
+	}
+}
+
+// waitForFile opens the followed file.  if -retry is set and the file does
+// not exist, it keeps trying until the file is created.
+func (f *follower) waitForFile() (os.FileInfo, error) {
+	for {
+		fi, err := f.open()
+		if err == nil || !*retry || !errors.Is(err, fs.ErrNotExist) {
+			return fi, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// pipe reads lines from a named pipe.  when the writer closes the pipe it is
+// reopened to wait for the next writer instead of stopping.  an inherited
+// pipe such as the one created by process substitution has only one writer,
+// so it is read until it is closed.
+func (f *follower) pipe(lines chan<- logLine) error {
+	for {
+		// a pipe may be written compressed data, like <(curl -s .../app.log.gz).
+		br, dec, err := decompress(f.path, f.rd)
+		if err != nil {
+			return err
+		}
+		f.rd = br
+		err = f.read(lines)
+		if dec != nil {
+			dec.Close()
+		}
+		if err != io.EOF {
+			return err
+		}
+		f.flush(lines)
+		if isFD(f.path) {
+			return nil
+		}
+		f.file.Close()
+		if _, err := f.waitForFile(); err != nil {
+			return err
+		}
+	}
+}
+
+// open opens the followed file and positions the reader at the start.
+func (f *follower) open() (os.FileInfo, error) {
+	return f.openPath(f.path)
+}
+
+// openPath opens the file at path in place of the followed file.
+func (f *follower) openPath(path string) (os.FileInfo, error) {
+	file, err := openFollowed(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f.file = file
+	f.id = getFileID(path, fi)
+	f.offset = 0
+	f.rd = bufio.NewReader(file)
+	f.pending, f.skipped = f.pending[:0], 0
+
+	return fi, nil
+}
+
+// seek moves the reader to the given offset of the followed file.
+func (f *follower) seek(off int64) error {
+	if _, err := f.file.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	f.offset = off
+	f.rd.Reset(f.file)
+	return nil
+}
+
+// read sends each complete line to the channel until it reaches the end of
+// the file or an error occurs.  a partial line at the end of the file is kept
+// until the rest of it is written.
+func (f *follower) read(lines chan<- logLine) error {
+	for {
+		b, err := f.rd.ReadSlice('\n')
+		f.pending = append(f.pending, b...)
+
+		// a line that is too long is dropped as it is read instead of
+		// growing forever.
+		if len(f.pending) > int(maxLineSize) {
+			f.skipped += int64(len(f.pending))
+			f.pending = f.pending[:0]
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		f.flush(lines)
+	}
+}
+
+// flush sends the pending line, if any, to the channel.
+func (f *follower) flush(lines chan<- logLine) {
+	if f.skipped > 0 {
+		f.skipped += int64(len(f.pending))
+		f.offset += f.skipped
+		l := longLine(f.skipped)
+		l.cur = &cursor{fileID: f.id, Offset: f.offset}
+		lines <- l
+		f.pending, f.skipped = f.pending[:0], 0
+		return
+	}
+	if len(f.pending) == 0 {
+		return
+	}
+
+	f.offset += int64(len(f.pending))
+	lines <- logLine{
+		data: bytes.Clone(trimNewline(f.pending)),
+		cur:  &cursor{fileID: f.id, Offset: f.offset},
+	}
+	f.pending = f.pending[:0]
+}
+
+// drainRotated reads what remains of a rotated file from the given offset.
+// the lines are attributed to the followed name rather than the rotated one.
+func (f *follower) drainRotated(path string, off int64, lines chan<- logLine) error {
+	old := &follower{path: f.path}
+	if _, err := old.openPath(path); err != nil {
+		return err
+	}
+	defer old.file.Close()
+
+	if err := old.seek(off); err != nil {
+		return err
+	}
+
+	if err := old.read(lines); err != io.EOF {
+		return err
+	}
+	old.flush(lines)
+
+	return nil
+}
+
+// findRotated looks for a rotated copy of path with the given id in the same
+// directory and returns its name, or an empty string if it was not found.
+func findRotated(path string, id fileID) string {
+	matches, err := filepath.Glob(path + "*")
+	if err != nil {
+		return ""
+	}
+
+	for _, match := range matches {
+		if match == path || isCompressedName(match) {
+			continue
+		}
+		fi, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if getFileID(match, fi) == id {
+			return match
+		}
+	}
+
+	return ""
+}
+
+// lastLinesOffset returns the offset of the start of the last n lines of
+// the file.
+func lastLinesOffset(file *os.File, size int64, n int) (int64, error) {
+	const chunk = 4096
+	buf := make([]byte, chunk)
+
+	off := size
+	count := 0
+	for off > 0 {
+		length := int64(chunk)
+		if off < length {
+			length = off
+		}
+		off -= length
+
+		if _, err := file.ReadAt(buf[:length], off); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := length - 1; i >= 0; i-- {
+			if buf[i] != '\n' || off+i == size-1 {
+				continue
+			}
+			count++
+			if count == n {
+				return off + i + 1, nil
+			}
+		}
+	}
+
+	return 0, nil
+}
+
+// returns true if the file is a named pipe.
+func isPipe(fi os.FileInfo) bool {
+	return fi.Mode()&os.ModeNamedPipe != 0
+}
+
+// returns true if the path refers to an inherited file descriptor.
+func isFD(path string) bool {
+	return strings.HasPrefix(path, "/dev/fd/") || strings.HasPrefix(path, "/proc/self/fd/") ||
+		path == "/dev/stdin"
+}
+
+// removes the trailing newline and carriage return from a line.
+func trimNewline(b []byte) []byte {
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	return bytes.TrimSuffix(b, []byte("\r"))
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// globSource follows every file matching a glob, or every file in a
+// directory, and the matching files that are created while it runs.  the
+// lines are labeled with the file they were read from.
+type globSource struct {
+	pattern string
+	files   []string // the files that matched when it was created.
+	lines   chan logLine
+}
+
+// isGlob returns true if a -tail argument is a glob or a directory.  a file
+// whose name looks like a glob is still followed as a file.
+func isGlob(spec string) bool {
+	fi, err := os.Stat(spec)
+	if err == nil {
+		return fi.IsDir()
+	}
+	return strings.ContainsAny(spec, "*?[")
+}
+
+func newGlobSource(spec string) *globSource {
+	pattern := spec
+	if fi, err := os.Stat(spec); err == nil && fi.IsDir() {
+		pattern = filepath.Join(spec, "*")
+	}
+	return &globSource{pattern: pattern, files: globFiles(pattern)}
+}
+
+// globFiles returns the sorted files matching the pattern, leaving out
+// directories and compressed files as those are rotated copies.
+func globFiles(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	files := matches[:0]
+	for _, m := range matches {
+		fi, err := os.Stat(m)
+		if err == nil && !fi.IsDir() && !isCompressedName(m) {
+			files = append(files, m)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (s *globSource) Open() error {
+	if _, err := filepath.Match(s.pattern, ""); err != nil {
+		return err
+	}
+	s.lines = make(chan logLine)
+	go s.watch()
+	return nil
+}
+
+// watch follows the files that match, checking for new ones as often as the
+// followed files are checked for new data.  a file that matches because an
+// older one was renamed to it, like app.log.1, is a rotated copy that the
+// follower of the old name reads what is left of.
+func (s *globSource) watch() {
+	followed := make(map[string]bool)
+	ids := make(map[fileID]bool)
+	first := true
+	for {
+		for f := range followed {
+			if fi, err := os.Stat(f); err == nil {
+				ids[getFileID(f, fi)] = true
+			}
+		}
+
+		files := s.files
+		if !first {
+			files = globFiles(s.pattern)
+		}
+		for _, f := range files {
+			if followed[f] {
+				continue
+			}
+			followed[f] = true
+			fi, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			id := getFileID(f, fi)
+			if ids[id] {
+				continue
+			}
+			ids[id] = true
+
+			// the files there at the start are followed like any other, the
+			// ones created later are read from the beginning.
+			var cur *cursor
+			if !first {
+				cur = &cursor{fileID: id}
+				s.lines <- logLine{notice: f + " created", file: f}
+			} else if cp != nil {
+				cur = cp.get(f)
+			}
+			go s.follow(f, cur)
+		}
+		first = false
+		time.Sleep(pollInterval)
+	}
+}
+
+// follow sends the lines of a file labeled with its name, an error ends the
+// file with a notice rather than every file of the glob.
+func (s *globSource) follow(file string, cur *cursor) {
+	lines := make(chan logLine)
+	errs := make(chan error, 1)
+	f := &follower{path: file}
+	go func() {
+		errs <- f.follow(cur, lines)
+	}()
+	for {
+		select {
+		case l := <-lines:
+			l.file = file
+			s.lines <- l
+		case err := <-errs:
+			if err != nil {
+				s.lines <- logLine{notice: file + ": " + err.Error(), file: file}
+			}
+			return
+		}
+	}
+}
+
+func (s *globSource) Next() (logLine, error) {
+	return <-s.lines, nil
+}
+
+// Close does nothing, the followers keep running until glogv exits.
+func (s *globSource) Close() error { return nil }
+
+func (s *globSource) Label() string { return s.pattern }
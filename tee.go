@@ -0,0 +1,171 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+var (
+	rawOutFile = flag.String("raw-out", "", "also write the lines that pass the filters to the given file exactly as they were read")
+	teeRaw     = flag.String("tee", "", "also write every line read to the given file as is, gzipped if it ends in .gz")
+	teeFmt     = flag.String("tee-formatted", "", "also write the displayed lines to the given file without colors, gzipped if it ends in .gz")
+)
+
+// teeFile is a file the lines are also written to, compressed with gzip if
+// its name ends in .gz.
+type teeFile struct {
+	file *os.File
+	gz   *gzip.Writer
+	*bufio.Writer
+}
+
+func createTee(path string) (*teeFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &teeFile{file: file}
+	if filepath.Ext(path) == ".gz" {
+		t.gz = gzip.NewWriter(file)
+		t.Writer = bufio.NewWriter(t.gz)
+	} else {
+		t.Writer = bufio.NewWriter(file)
+	}
+	return t, nil
+}
+
+// writeLine writes b followed by a newline.  a failed write is reported when
+// the file is closed.
+func (t *teeFile) writeLine(b []byte) {
+	t.Write(b)
+	t.WriteByte('\n')
+}
+
+// Flush writes the buffered lines to the file, a gzipped file is flushed so
+// what was written so far can be read while following.
+func (t *teeFile) Flush() error {
+	err := t.Writer.Flush()
+	if t.gz != nil && err == nil {
+		err = t.gz.Flush()
+	}
+	return err
+}
+
+func (t *teeFile) Close() error {
+	err := t.Writer.Flush()
+	if t.gz != nil {
+		err = errors.Join(err, t.gz.Close())
+	}
+	return errors.Join(err, t.file.Close())
+}
+
+// the -raw-out, -tee and -tee-formatted files, nil if they are not used.
+var rawOut, teeOut, teeFmtOut *teeFile
+
+// openTees creates the -raw-out, -tee and -tee-formatted files.
+func openTees() error {
+	var err error
+	for _, t := range []struct {
+		path string
+		tee  **teeFile
+	}{{*rawOutFile, &rawOut}, {*teeRaw, &teeOut}, {*teeFmt, &teeFmtOut}} {
+		if t.path == "" {
+			continue
+		}
+		if *t.tee, err = createTee(t.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the line being displayed as it was read, with the partial container runtime
+// lines it continues, and whether it was written to -raw-out.  the partial
+// lines of each source are held until the line that completes them.
+var (
+	rawLine    []byte
+	rawWritten bool
+	rawHeld    = make(map[string][]byte)
+)
+
+// holdRaw keeps a partial container runtime line for -raw-out.
+func holdRaw(src string, b []byte) {
+	if rawOut != nil {
+		rawHeld[src] = append(append(rawHeld[src], b...), '\n')
+	}
+}
+
+// setRawLine sets the line being displayed, nil for the partial lines of a
+// source that ended without the rest of them.
+func setRawLine(src string, b []byte) {
+	rawLine, rawWritten = b, false
+	if held, ok := rawHeld[src]; ok {
+		delete(rawHeld, src)
+		if b == nil {
+			held = held[:len(held)-1]
+		}
+		rawLine = append(held, b...)
+	}
+}
+
+// writeRaw writes an entry or a plain line that passed the filters to the
+// -raw-out file as it was read, once for all of the entries of a Records
+// wrapper.  a -multiline document is written as b, the lines it was joined
+// from.
+func writeRaw(b []byte) {
+	if rawOut == nil {
+		return
+	}
+	if !*multiline {
+		if rawWritten {
+			return
+		}
+		b, rawWritten = rawLine, true
+	}
+	if b != nil {
+		rawOut.writeLine(b)
+	}
+}
+
+// writeTee writes a line as it was read to the -tee file.
+func writeTee(b []byte) {
+	if teeOut == nil {
+		return
+	}
+	teeOut.writeLine(b)
+}
+
+// writeFormatted writes a displayed line to the -tee-formatted file without
+// its colors.
+func writeFormatted(b []byte) {
+	if teeFmtOut == nil {
+		return
+	}
+	teeFmtOut.Write(stripColors(b))
+}
+
+// flushTees flushes the files, which is done after every line along with the
+// output when following.
+func flushTees() {
+	for _, t := range []*teeFile{rawOut, teeOut, teeFmtOut} {
+		if t != nil {
+			t.Flush()
+		}
+	}
+}
+
+func closeTees() error {
+	var err error
+	for _, t := range []*teeFile{rawOut, teeOut, teeFmtOut} {
+		if t != nil {
+			err = errors.Join(err, t.Close())
+		}
+	}
+	return err
+}
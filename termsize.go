@@ -0,0 +1,27 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+// termSize returns the number of rows and columns of the terminal on stdout.
+func termSize() (rows, cols int, ok bool) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || rows == 0 {
+		return 0, 0, false
+	}
+	return rows, cols, true
+}
+
+// rawTerminal switches the terminal to raw mode so keys are read as they are
+// pressed, ctrl-c is read as a key that quits like q.
+func rawTerminal(tty *os.File) (restore func(), err error) {
+	old, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	return func() { term.Restore(int(tty.Fd()), old) }, nil
+}
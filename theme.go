@@ -0,0 +1,176 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"maps"
+)
+
+// theme is a set of colors used to display log entries.
+type theme struct {
+	levels map[string]string // colors of the message and values by level.
+	labels map[string]string // colors of the level label, defaults to levels.
+	time   string
+	tag    string
+	info   string
+	warn   string
+}
+
+// built-in themes that can be selected with the -theme option.  the
+// colorblind themes encode the level with bold, underline and background
+// styles as well as hue, and reset before every color so a style never
+// bleeds into the next part of the line.
+var themes = map[string]theme{
+	"default": {
+		levels: color,
+		time:   timeColor,
+		tag:    tagColor,
+		info:   infoColor,
+		warn:   warnColor,
+	},
+	"deuteranopia": {
+		levels: map[string]string{
+			"trace": "\033[0;2;36m",
+			"debug": "\033[0;36m",
+			"info":  "\033[0;34m",
+			"warn":  "\033[0;1;33m",
+			"error": "\033[0;1;4;35m",
+			"panic": "\033[0;1;4;35m",
+			"fatal": "\033[0;1;4;35m",
+		},
+		labels: map[string]string{
+			"trace": "\033[0;2;36m",
+			"debug": "\033[0;36m",
+			"info":  "\033[0;34m",
+			"warn":  "\033[0;1;4;33m",
+			"error": "\033[0;1;97;45m",
+			"panic": "\033[0;1;4;97;45m",
+			"fatal": "\033[0;1;4;97;45m",
+		},
+		time: "\033[0;90m",
+		tag:  "\033[0;90m",
+		info: "\033[0;37m",
+		warn: "\033[0;1;33m",
+	},
+	"light": {
+		levels: map[string]string{
+			"info":  "\033[0;38;5;28m",
+			"warn":  "\033[0;38;5;130m",
+			"debug": "\033[0;38;5;30m",
+			"error": "\033[0;38;5;124m",
+			"panic": "\033[0;38;5;90m",
+			"fatal": "\033[0;38;5;90m",
+			"trace": "\033[0;38;5;30m",
+		},
+		time: "\033[0;38;5;242m",
+		tag:  "\033[0;38;5;242m",
+		info: "\033[0;30m",
+		warn: "\033[0;38;5;130m",
+	},
+	"solarized": {
+		levels: map[string]string{
+			"trace": "\033[38;2;88;110;117m",
+			"debug": "\033[38;2;42;161;152m",
+			"info":  "\033[38;2;133;153;0m",
+			"warn":  "\033[38;2;181;137;0m",
+			"error": "\033[38;2;220;50;47m",
+			"panic": "\033[38;2;211;54;130m",
+			"fatal": "\033[38;2;211;54;130m",
+		},
+		time: "\033[38;2;88;110;117m",
+		tag:  "\033[38;2;88;110;117m",
+		info: "\033[38;2;131;148;150m",
+		warn: "\033[38;2;203;75;22m",
+	},
+	"tritanopia": {
+		levels: map[string]string{
+			"trace": "\033[0;2;37m",
+			"debug": "\033[0;36m",
+			"info":  "\033[0;37m",
+			"warn":  "\033[0;1;31m",
+			"error": "\033[0;1;4;31m",
+			"panic": "\033[0;1;4;31m",
+			"fatal": "\033[0;1;4;31m",
+		},
+		labels: map[string]string{
+			"trace": "\033[0;2;37m",
+			"debug": "\033[0;36m",
+			"info":  "\033[0;1;36m",
+			"warn":  "\033[0;1;4;31m",
+			"error": "\033[0;1;97;41m",
+			"panic": "\033[0;1;4;97;41m",
+			"fatal": "\033[0;1;4;97;41m",
+		},
+		time: "\033[0;90m",
+		tag:  "\033[0;90m",
+		info: "\033[0;37m",
+		warn: "\033[0;1;31m",
+	},
+}
+
+// lightTerminal is set when the light theme was picked for the background of
+// the terminal, so a reload of the config file does not ask the terminal again.
+var lightTerminal bool
+
+// pickTheme returns the theme to use.  if one was not chosen with -theme then
+// the light theme is picked for light terminal backgrounds.
+func pickTheme() (string, error) {
+	bg := *background
+	switch bg {
+	case "auto":
+		if flagSet("theme") {
+			return *themeName, nil
+		}
+		bg = detectBackground()
+	case "light", "dark":
+	default:
+		return "", fmt.Errorf("unknown -background %q", bg)
+	}
+
+	if bg == "light" && !flagSet("theme") {
+		lightTerminal = true
+		return "light", nil
+	}
+	return *themeName, nil
+}
+
+// applyTheme replaces the current colors with those of the named theme.
+func applyTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown -theme %q", name)
+	}
+
+	// the maps are copied as the config file colors are set in them.
+	color = maps.Clone(t.levels)
+	labelColor = color
+	if t.labels != nil {
+		labelColor = maps.Clone(t.labels)
+	}
+	timeColor = t.time
+	tagColor = t.tag
+	infoColor = t.info
+	warnColor = t.warn
+
+	return nil
+}
+
+// disableColors removes all colors and styles from the output.
+func disableColors() {
+	for _, m := range []map[string]string{color, labelColor} {
+		for k := range m {
+			m[k] = ""
+		}
+	}
+	for i := range markColors {
+		markColors[i] = ""
+	}
+	timeColor = ""
+	tagColor = ""
+	infoColor = ""
+	warnColor = ""
+	alarmColor = ""
+	dimColor = ""
+	highlightColor = ""
+	colorReset = ""
+}
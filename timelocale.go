@@ -0,0 +1,154 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var timeLocale = flag.String("time-locale", "", "language of the month and day names in times (de, es, fr, it, nl, pt)")
+
+// localeNames are the month and day names of a language, each with the full
+// name first and then the abbreviations.  the days start on sunday.
+type localeNames struct {
+	months [12][]string
+	days   [7][]string
+}
+
+var locales = map[string]localeNames{
+	"de": {
+		months: [12][]string{
+			{"januar", "jan"}, {"februar", "feb"}, {"märz", "mär", "mrz"}, {"april", "apr"},
+			{"mai"}, {"juni", "jun"}, {"juli", "jul"}, {"august", "aug"},
+			{"september", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"dezember", "dez"},
+		},
+		days: [7][]string{
+			{"sonntag", "so"}, {"montag", "mo"}, {"dienstag", "di"}, {"mittwoch", "mi"},
+			{"donnerstag", "do"}, {"freitag", "fr"}, {"samstag", "sa"},
+		},
+	},
+	"es": {
+		months: [12][]string{
+			{"enero", "ene"}, {"febrero", "feb"}, {"marzo", "mar"}, {"abril", "abr"},
+			{"mayo", "may"}, {"junio", "jun"}, {"julio", "jul"}, {"agosto", "ago"},
+			{"septiembre", "sept", "sep"}, {"octubre", "oct"}, {"noviembre", "nov"}, {"diciembre", "dic"},
+		},
+		days: [7][]string{
+			{"domingo", "dom"}, {"lunes", "lun"}, {"martes"}, {"miércoles", "mié"},
+			{"jueves", "jue"}, {"viernes", "vie"}, {"sábado", "sáb"},
+		},
+	},
+	"fr": {
+		months: [12][]string{
+			{"janvier", "janv"}, {"février", "févr"}, {"mars"}, {"avril", "avr"},
+			{"mai"}, {"juin"}, {"juillet", "juil"}, {"août"},
+			{"septembre", "sept"}, {"octobre", "oct"}, {"novembre", "nov"}, {"décembre", "déc"},
+		},
+		days: [7][]string{
+			{"dimanche", "dim"}, {"lundi", "lun"}, {"mardi"}, {"mercredi", "mer"},
+			{"jeudi", "jeu"}, {"vendredi", "ven"}, {"samedi", "sam"},
+		},
+	},
+	"it": {
+		months: [12][]string{
+			{"gennaio", "gen"}, {"febbraio", "feb"}, {"marzo", "mar"}, {"aprile", "apr"},
+			{"maggio", "mag"}, {"giugno", "giu"}, {"luglio", "lug"}, {"agosto", "ago"},
+			{"settembre", "set"}, {"ottobre", "ott"}, {"novembre", "nov"}, {"dicembre", "dic"},
+		},
+		days: [7][]string{
+			{"domenica", "dom"}, {"lunedì", "lun"}, {"martedì"}, {"mercoledì", "mer"},
+			{"giovedì", "gio"}, {"venerdì", "ven"}, {"sabato", "sab"},
+		},
+	},
+	"nl": {
+		months: [12][]string{
+			{"januari", "jan"}, {"februari", "feb"}, {"maart", "mrt"}, {"april", "apr"},
+			{"mei"}, {"juni", "jun"}, {"juli", "jul"}, {"augustus", "aug"},
+			{"september", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"december", "dec"},
+		},
+		days: [7][]string{
+			{"zondag", "zo"}, {"maandag", "ma"}, {"dinsdag", "di"}, {"woensdag", "wo"},
+			{"donderdag", "do"}, {"vrijdag", "vr"}, {"zaterdag", "za"},
+		},
+	},
+	"pt": {
+		months: [12][]string{
+			{"janeiro", "jan"}, {"fevereiro", "fev"}, {"março", "mar"}, {"abril", "abr"},
+			{"maio", "mai"}, {"junho", "jun"}, {"julho", "jul"}, {"agosto", "ago"},
+			{"setembro", "set"}, {"outubro", "out"}, {"novembro", "nov"}, {"dezembro", "dez"},
+		},
+		days: [7][]string{
+			{"domingo", "dom"}, {"segunda-feira", "seg"}, {"terça-feira", "ter"}, {"quarta-feira", "qua"},
+			{"quinta-feira", "qui"}, {"sexta-feira", "sex"}, {"sábado", "sáb"},
+		},
+	},
+}
+
+// timeNames translates the month and day names of -time-locale to english,
+// nil if it is not set.
+var timeNames *strings.Replacer
+
+// setTimeLocale builds the translation of the names of -time-locale.  the
+// names are matched in lower case, capitalized and with a trailing dot.
+// longer names come first so a name is not taken for the abbreviation it
+// starts with, and months come before days so an abbreviation shared with a
+// day is a month.
+func setTimeLocale() error {
+	timeNames = nil
+	if *timeLocale == "" {
+		return nil
+	}
+	loc, ok := locales[strings.ToLower(*timeLocale)]
+	if !ok {
+		return fmt.Errorf("unknown -time-locale %q", *timeLocale)
+	}
+
+	var pairs [][2]string
+	add := func(names []string, full string) {
+		for i, name := range names {
+			en := full
+			if i > 0 {
+				en = full[:3]
+			}
+			for _, s := range []string{name, capitalize(name)} {
+				if i > 0 {
+					pairs = append(pairs, [2]string{s + ".", en})
+				}
+				pairs = append(pairs, [2]string{s, en})
+			}
+		}
+	}
+	for i, names := range loc.months {
+		add(names, time.Month(i+1).String())
+	}
+	for i, names := range loc.days {
+		add(names, time.Weekday(i).String())
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return len(pairs[i][0]) > len(pairs[j][0])
+	})
+	args := make([]string, 0, 2*len(pairs))
+	for _, p := range pairs {
+		args = append(args, p[0], p[1])
+	}
+	timeNames = strings.NewReplacer(args...)
+	return nil
+}
+
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// localizeTime returns s with the names of -time-locale in english.
+func localizeTime(s string) string {
+	if timeNames == nil {
+		return s
+	}
+	return timeNames.Replace(s)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var timeParse = flag.String("time-parse", "", "layout of times that are not RFC 3339 or a number, a go layout or a strptime pattern like \"%Y-%m-%d %H:%M:%S,%f\"")
+
+// the go layouts of the strptime directives.
+var strptimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'f': "999999999",
+	'p': "PM",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'D': "01/02/06",
+	'%': "%",
+}
+
+// the layouts of -time-parse, nil if it is not set.
+var timeLayouts []string
+
+// setTimeParse sets the layout of -time-parse, a pattern with a % is taken as
+// strptime.
+func setTimeParse() error {
+	timeLayouts = nil
+	if *timeParse == "" {
+		return nil
+	}
+	layout := *timeParse
+	if strings.Contains(layout, "%") {
+		var err error
+		if layout, err = strptimeLayout(layout); err != nil {
+			return fmt.Errorf("-time-parse: %w", err)
+		}
+	}
+	timeLayouts = []string{layout}
+	return nil
+}
+
+// strptimeLayout converts a strptime pattern to a go layout.  %f takes any
+// number of digits, like the fraction of the seconds in python.
+func strptimeLayout(pattern string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			sb.WriteByte(pattern[i])
+			continue
+		}
+		if i+1 == len(pattern) {
+			return "", fmt.Errorf("%q ends with %%", pattern)
+		}
+		i++
+		layout, ok := strptimeDirectives[pattern[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown directive %%%c in %q", pattern[i], pattern)
+		}
+		sb.WriteString(layout)
+	}
+	return sb.String(), nil
+}
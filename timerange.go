@@ -0,0 +1,94 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	sinceFlag = flag.String("since", "", "hide entries before a time like \"2024-01-02 10:00\" or a duration ago like 15m")
+	untilFlag = flag.String("until", "", "hide entries after a time like \"2024-01-02 10:00\" or a duration ago like 15m")
+)
+
+// the layouts accepted by -since and -until, a time without a date is today.
+var rangeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// the -since and -until times, zero when not set.
+var since, until time.Time
+
+// setTimeRange parses -since and -until, times without a zone are in the
+// zone times are displayed in.
+func setTimeRange(now time.Time) error {
+	var err error
+	if since, err = parseRangeTime("since", *sinceFlag, now); err != nil {
+		return err
+	}
+	if until, err = parseRangeTime("until", *untilFlag, now); err != nil {
+		return err
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return fmt.Errorf("-until is before -since")
+	}
+	return nil
+}
+
+func parseRangeTime(name, s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, ok := parseAgo(s); ok {
+		return now.Add(-d), nil
+	}
+
+	loc := timeZone
+	if loc == nil {
+		loc = time.Local
+	}
+	for _, layout := range rangeLayouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			y, m, d := now.In(loc).Date()
+			t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("-%s: %q is not a time like \"2024-01-02 10:00\" or a duration like 15m", name, s)
+}
+
+// parseAgo parses a duration, which may also be a number of days like 2d.
+func parseAgo(s string) (time.Duration, bool) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return time.Duration(n) * 24 * time.Hour, err == nil && n >= 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d >= 0
+}
+
+// inTimeRange returns false if the entry is outside of -since and -until.
+// entries without a time are always shown.
+func inTimeRange(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if !since.IsZero() && t.Before(since) {
+		return false
+	}
+	return until.IsZero() || !t.After(until)
+}
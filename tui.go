@@ -0,0 +1,786 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cwbriscoe/glogv/format"
+	"github.com/goccy/go-json"
+)
+
+// the number of lines kept by -tui, the oldest tenth is dropped when there
+// are more, and how often new lines are drawn.
+const (
+	tuiMaxLines = 100000
+	tuiInterval = 50 * time.Millisecond
+)
+
+// what the keys of the -tui do.
+const (
+	tuiBrowse  = iota // move around the lines.
+	tuiInput          // type a search or filter.
+	tuiInspect        // look at the json of a line.
+	tuiHelp           // look at the keys.
+)
+
+// the levels toggled with the number keys of the -tui, fatal also toggles
+// panic as they share a label.
+var tuiLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+var tuiKeys = []string{
+	"j k up down    move the selection",
+	"pgup pgdn      move a page",
+	"g G home end   go to the first line or follow the last one",
+	"space p        pause or resume following",
+	"/ n N          search, next and previous match",
+	"f              only show lines matching a regular expression",
+	"c              clear the filter and the search",
+	"tab            switch between the panes of -split",
+	":              change a filter of new lines, like :grep timeout",
+	"1-6            toggle trace, debug, info, warn, error and fatal",
+	"enter          inspect the json of the selected line",
+	"q              quit",
+}
+
+// the -tui that displayed lines are handed to, nil without -tui.
+var tui *tuiView
+
+// tuiLine is a displayed line along with the entry it came from.
+type tuiLine struct {
+	text  []byte // the line as it would have been written.
+	raw   []byte // the entry as it was read, nil for other lines.
+	level string
+	src   string    // the source of the line, empty for warnings and notices.
+	time  time.Time // the time of the entry, or of the entry before the line.
+}
+
+// tuiPane shows the lines of some of the sources with its own filter and
+// selection.
+type tuiPane struct {
+	src    string // the only source shown, or any source if empty.
+	others bool   // show every source except src instead.
+	shown  []int  // the indexes of the lines that pass the filters.
+	filter *regexp.Regexp
+	search *regexp.Regexp
+	sel    int // the index in shown of the selected line.
+	top    int // the index in shown of the first line on the screen.
+}
+
+// tuiView keeps a scrollable buffer of the displayed lines and draws the
+// lines that pass its filters on the alternate screen of the terminal, in
+// two panes side by side with -split.
+type tuiView struct {
+	mu      sync.Mutex
+	tty     *os.File
+	restore func()
+	lines   []tuiLine
+	panes   []*tuiPane
+	pane    *tuiPane // the pane the keys act on.
+	hidden  map[string]bool
+	follow  bool
+	mode    int
+	prompt  byte // '/', 'f' or ':' while typing.
+	input   []byte
+	page    []string // the lines of the inspected json or the help.
+	scroll  int
+	message string
+	dirty   bool
+	closed  bool
+	rows    int
+	quit    chan struct{}
+	// the filter commands typed after ':', which are run by the tail.
+	commands chan string
+	buf      []byte
+	last     map[string]time.Time // the time of the last entry of each source.
+	panel    []string             // the rows of the -panel and -status pane.
+}
+
+// newTUI takes over the terminal, keys are read from /dev/tty so stdin can
+// still be followed.
+func newTUI() (*tuiView, error) {
+	if !isTerminal(os.Stdout) {
+		return nil, errors.New("-tui needs a terminal")
+	}
+	if _, _, ok := termSize(); !ok {
+		return nil, errors.New("-tui can not get the size of the terminal")
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, fmt.Errorf("-tui: %w", err)
+	}
+	restore, err := rawTerminal(tty)
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+
+	t := &tuiView{
+		tty:      tty,
+		restore:  restore,
+		hidden:   make(map[string]bool),
+		follow:   true,
+		dirty:    true,
+		quit:     make(chan struct{}),
+		commands: make(chan string, 1),
+		last:     make(map[string]time.Time),
+	}
+	t.panes = []*tuiPane{{}}
+	t.pane = t.panes[0]
+	os.Stdout.WriteString("\033[?1049h\033[?25l")
+	go t.readKeys()
+	go t.refresh()
+	return t, nil
+}
+
+// split shows the first source in the left pane and the others in the right
+// one, a single source is shown in both so they can be filtered differently.
+func (t *tuiView) split(labels []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	left, right := &tuiPane{}, &tuiPane{}
+	if len(labels) > 1 {
+		left.src = labels[0]
+		right.src, right.others = labels[0], true
+	}
+	t.panes = []*tuiPane{left, right}
+	t.pane = left
+	t.dirty = true
+}
+
+// close gives the terminal back.
+func (t *tuiView) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	os.Stdout.WriteString("\033[?25h\033[?1049l")
+	t.restore()
+}
+
+// add adds a displayed line, which may span several lines with -expand.  e
+// is the entry of the line, nil for other lines.
+func (t *tuiView) add(text []byte, src string, e *Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var raw []byte
+	var level string
+	if e != nil {
+		raw, level = bytes.Clone(e.Raw), e.Level
+		t.last[src] = e.Time
+	}
+	text = bytes.TrimSuffix(text, []byte("\n"))
+	for _, s := range bytes.Split(text, []byte("\n")) {
+		l := tuiLine{text: bytes.Clone(s), raw: raw, level: level, src: src, time: t.last[src]}
+		t.lines = append(t.lines, l)
+		for _, p := range t.panes {
+			if t.pass(p, &l) {
+				p.shown = append(p.shown, len(t.lines)-1)
+			}
+		}
+	}
+
+	if len(t.lines) > tuiMaxLines {
+		drop := len(t.lines) / 10
+		t.lines = append(t.lines[:0], t.lines[drop:]...)
+		for _, p := range t.panes {
+			t.rebuild(p, t.selected(p)-drop)
+		}
+	}
+	t.dirty = true
+}
+
+// setPanel sets the rows of the pane of -panel and -status on the right.
+func (t *tuiView) setPanel(rows []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.panel = rows
+	t.dirty = true
+}
+
+// pass returns true if the line is shown in the pane and not hidden by its
+// level or the filter of the pane.
+func (t *tuiView) pass(p *tuiPane, l *tuiLine) bool {
+	if t.hidden[l.level] {
+		return false
+	}
+	if p.src != "" && l.src != "" && (l.src == p.src) == p.others {
+		return false
+	}
+	return p.filter == nil || p.filter.Match(stripColors(l.text))
+}
+
+// selected returns the index in lines of the selected line of the pane, -1 if
+// none.
+func (t *tuiView) selected(p *tuiPane) int {
+	if p.sel < 0 || p.sel >= len(p.shown) {
+		return -1
+	}
+	return p.shown[p.sel]
+}
+
+// rebuild applies the filters of the pane again, keeping the selection on the
+// line at index sel of lines or the closest one after it.
+func (t *tuiView) rebuild(p *tuiPane, sel int) {
+	p.shown = p.shown[:0]
+	p.sel = -1
+	for i := range t.lines {
+		if !t.pass(p, &t.lines[i]) {
+			continue
+		}
+		if p.sel < 0 && i >= sel {
+			p.sel = len(p.shown)
+		}
+		p.shown = append(p.shown, i)
+	}
+	if p.sel < 0 {
+		p.sel = len(p.shown) - 1
+	}
+}
+
+// rebuildAll applies the filters of every pane again.
+func (t *tuiView) rebuildAll() {
+	for _, p := range t.panes {
+		t.rebuild(p, t.selected(p))
+	}
+}
+
+// syncPanes selects the line of the other panes at the time of the selected
+// line of the active pane, so they scroll through time together.
+func (t *tuiView) syncPanes() {
+	if len(t.panes) < 2 || t.follow {
+		return
+	}
+	i := t.selected(t.pane)
+	if i < 0 {
+		return
+	}
+	at := t.lines[i].time
+	for _, p := range t.panes {
+		if p == t.pane {
+			continue
+		}
+		// the last line at or before the time, or the first line.
+		p.sel = 0
+		for j, n := range p.shown {
+			if t.lines[n].time.After(at) {
+				break
+			}
+			p.sel = j
+		}
+	}
+}
+
+// refresh draws the new lines every tuiInterval.
+func (t *tuiView) refresh() {
+	ticker := time.NewTicker(tuiInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return
+		}
+		if t.dirty {
+			t.draw()
+		}
+		t.mu.Unlock()
+	}
+}
+
+// readKeys handles the keys until the terminal is closed.
+func (t *tuiView) readKeys() {
+	buf := make([]byte, 64)
+	for {
+		n, err := t.tty.Read(buf)
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return
+		}
+		for k := buf[:n]; len(k) > 0; {
+			size := keyLen(k)
+			t.key(string(k[:size]))
+			k = k[size:]
+		}
+		t.draw()
+		t.mu.Unlock()
+	}
+}
+
+// keyLen returns the length of the key at the start of b, which is an escape
+// sequence, a lone escape or a character.
+func keyLen(b []byte) int {
+	if b[0] == '\033' {
+		if len(b) > 1 && (b[1] == '[' || b[1] == 'O') {
+			return escapeLen(b)
+		}
+		return 1
+	}
+	_, size := utf8.DecodeRune(b)
+	return size
+}
+
+// key handles a single key.
+func (t *tuiView) key(k string) {
+	t.message = ""
+	if k == "\x03" {
+		t.stop()
+		return
+	}
+	switch t.mode {
+	case tuiInput:
+		t.inputKey(k)
+	case tuiInspect, tuiHelp:
+		t.pageKey(k)
+	default:
+		t.browseKey(k)
+		t.syncPanes()
+	}
+}
+
+func (t *tuiView) browseKey(k string) {
+	body := t.rows - 1
+	p := t.pane
+	switch k {
+	case "q":
+		t.stop()
+	case " ", "p":
+		t.follow = !t.follow
+	case "j", "\033[B", "\033OB":
+		t.move(1)
+	case "k", "\033[A", "\033OA":
+		t.move(-1)
+	case "\033[6~", "\x06":
+		t.move(body)
+	case "\033[5~", "\x02":
+		t.move(-body)
+	case "g", "\033[H", "\033[1~":
+		t.follow = false
+		p.sel = 0
+	case "G", "\033[F", "\033[4~":
+		t.follow = true
+	case "/", "f", ":":
+		t.mode, t.prompt, t.input = tuiInput, k[0], t.input[:0]
+	case "n":
+		t.find(1)
+	case "N":
+		t.find(-1)
+	case "c":
+		p.filter, p.search = nil, nil
+		t.rebuild(p, t.selected(p))
+	case "\t":
+		for i, other := range t.panes {
+			if other == p {
+				t.pane = t.panes[(i+1)%len(t.panes)]
+				break
+			}
+		}
+	case "\r", "\n":
+		t.inspect()
+	case "?":
+		t.mode, t.page, t.scroll = tuiHelp, tuiKeys, 0
+	default:
+		if len(k) == 1 && k[0] >= '1' && k[0] < '1'+byte(len(tuiLevels)) {
+			name := tuiLevels[k[0]-'1']
+			t.hidden[name] = !t.hidden[name]
+			if name == "fatal" {
+				t.hidden["panic"] = t.hidden[name]
+			}
+			t.rebuildAll()
+		}
+	}
+}
+
+// stop tells the tail to quit.
+func (t *tuiView) stop() {
+	select {
+	case <-t.quit:
+	default:
+		close(t.quit)
+	}
+}
+
+// move moves the selection of the active pane and stops following.
+func (t *tuiView) move(n int) {
+	p := t.pane
+	t.follow = false
+	p.sel = max(0, min(p.sel+n, len(p.shown)-1))
+}
+
+func (t *tuiView) inputKey(k string) {
+	switch k {
+	case "\033":
+		t.mode = tuiBrowse
+	case "\x7f", "\b":
+		if len(t.input) > 0 {
+			_, size := utf8.DecodeLastRune(t.input)
+			t.input = t.input[:len(t.input)-size]
+		}
+	case "\r", "\n":
+		t.mode = tuiBrowse
+		if t.prompt == ':' {
+			t.command()
+			return
+		}
+		var re *regexp.Regexp
+		if len(t.input) > 0 {
+			re = compileSearch(string(t.input))
+		}
+		if p := t.pane; t.prompt == 'f' {
+			p.filter = re
+			t.rebuild(p, t.selected(p))
+		} else {
+			p.search = re
+			t.find(0)
+			t.syncPanes()
+		}
+	default:
+		if k[0] >= ' ' {
+			t.input = append(t.input, k...)
+		}
+	}
+}
+
+func (t *tuiView) pageKey(k string) {
+	switch k {
+	case "j", "\033[B":
+		t.scroll = min(t.scroll+1, max(0, len(t.page)-(t.rows-1)))
+	case "k", "\033[A":
+		t.scroll = max(0, t.scroll-1)
+	case "q", "\033", "\r", "\n", "?":
+		t.mode = tuiBrowse
+	}
+}
+
+// command hands the typed filter command to the tail, which shows the result
+// as a line.
+func (t *tuiView) command() {
+	select {
+	case t.commands <- string(t.input):
+	default:
+		t.message = "busy, try again"
+	}
+}
+
+// compileSearch compiles a case insensitive search, which is taken literally
+// if it is not a valid regular expression.
+func compileSearch(s string) *regexp.Regexp {
+	re, err := regexp.Compile("(?i)" + s)
+	if err != nil {
+		re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(s))
+	}
+	return re
+}
+
+// find selects the next match of the search of the active pane in the
+// direction of dir, a dir of 0 also matches the selected line.
+func (t *tuiView) find(dir int) {
+	p := t.pane
+	if p.search == nil || len(p.shown) == 0 {
+		return
+	}
+	step, i := dir, p.sel+dir
+	if step == 0 {
+		step, i = 1, max(p.sel, 0)
+	}
+	for ; i >= 0 && i < len(p.shown); i += step {
+		if p.search.Match(stripColors(t.lines[p.shown[i]].text)) {
+			t.follow, p.sel = false, i
+			return
+		}
+	}
+	t.message = "no match"
+}
+
+// inspect shows the json of the selected line.
+func (t *tuiView) inspect() {
+	i := t.selected(t.pane)
+	if i < 0 {
+		return
+	}
+	l := &t.lines[i]
+	text := stripColors(l.text)
+	if l.raw != nil {
+		var b bytes.Buffer
+		if err := json.Indent(&b, l.raw, "", "  "); err == nil {
+			text = b.Bytes()
+		} else {
+			text = l.raw
+		}
+	}
+	t.mode, t.scroll = tuiInspect, 0
+	t.page = strings.Split(string(text), "\n")
+}
+
+// draw redraws the whole screen.
+func (t *tuiView) draw() {
+	rows, cols, ok := termSize()
+	if !ok || rows < 2 {
+		return
+	}
+	t.rows, t.dirty = rows, false
+	body := rows - 1
+
+	b := append(t.buf[:0], "\033[H"...)
+	if t.mode == tuiInspect || t.mode == tuiHelp {
+		for row := 0; row < body; row++ {
+			b = append(b, "\033[2K"...)
+			if i := t.scroll + row; i < len(t.page) {
+				b = appendVisible(b, []byte(t.page[i]), cols)
+			}
+			b = append(b, "\r\n"...)
+		}
+	} else {
+		// the panes share the width, with a line between them.  the -panel
+		// is on the right if the terminal is wide enough for it.
+		panel := t.panel != nil && cols >= 2*panelTUIWidth
+		lines := cols
+		if panel {
+			lines -= panelTUIWidth + 1
+		}
+		width := (lines - len(t.panes) + 1) / len(t.panes)
+		for _, p := range t.panes {
+			t.scrollPane(p, body)
+		}
+		for row := 0; row < body; row++ {
+			b = append(b, "\033[2K"...)
+			for n, p := range t.panes {
+				if n > 0 {
+					b = appendDivider(b)
+				}
+				b = t.appendPaneRow(b, p, row, width, panel)
+			}
+			if panel {
+				b = appendDivider(b)
+				if row < len(t.panel) {
+					b = appendVisible(b, []byte(t.panel[row]), panelTUIWidth)
+					b = append(b, "\033[0m"...)
+				}
+			}
+			b = append(b, "\r\n"...)
+		}
+	}
+
+	b = append(b, "\033[2K\033[7m"...)
+	b = appendVisible(b, []byte(t.status()), cols)
+	b = append(b, "\033[0m"...)
+	t.buf = b
+	os.Stdout.Write(b)
+}
+
+// scrollPane keeps the selection of the pane on the screen.
+func (t *tuiView) scrollPane(p *tuiPane, body int) {
+	if t.follow || p.sel >= len(p.shown) {
+		p.sel = len(p.shown) - 1
+	}
+	if p.sel < p.top {
+		p.top = p.sel
+	}
+	if p.sel >= p.top+body {
+		p.top = p.sel - body + 1
+	}
+	p.top = max(0, min(p.top, len(p.shown)-body))
+}
+
+// appendDivider appends the line between two panes.
+func appendDivider(b []byte) []byte {
+	b = append(b, tagColor...)
+	b = append(b, "│"...)
+	return append(b, "\033[0m"...)
+}
+
+// appendPaneRow appends a row of the pane, padded to width if something is
+// drawn to the right of it.  the selection is only marked in the active pane
+// when there are more than one.
+func (t *tuiView) appendPaneRow(b []byte, p *tuiPane, row, width int, panel bool) []byte {
+	pad := len(t.panes) > 1 || panel
+	i := p.top + row
+	if i >= len(p.shown) {
+		if !pad {
+			return b
+		}
+		return append(b, strings.Repeat(" ", width)...)
+	}
+	switch {
+	case i != p.sel:
+		b = append(b, "  "...)
+	case p == t.pane || len(t.panes) == 1:
+		b = append(b, "\033[7m>\033[0m "...)
+	default:
+		b = append(b, "> "...)
+	}
+	text := t.lines[p.shown[i]].text
+	b = appendVisible(b, text, width-2)
+	b = append(b, "\033[0m"...)
+	if pad {
+		b = append(b, strings.Repeat(" ", max(0, width-2-visibleLen(text)))...)
+	}
+	return b
+}
+
+// status returns the line at the bottom of the screen.
+func (t *tuiView) status() string {
+	switch t.mode {
+	case tuiInput:
+		return string(t.prompt) + string(t.input)
+	case tuiInspect:
+		return " json of the selected line, q to go back"
+	case tuiHelp:
+		return " keys, q to go back"
+	}
+
+	var sb strings.Builder
+	if t.follow {
+		sb.WriteString(" FOLLOW")
+	} else {
+		sb.WriteString(" PAUSED")
+	}
+	p := t.pane
+	if len(t.panes) > 1 {
+		if p == t.panes[0] {
+			sb.WriteString("  left")
+		} else {
+			sb.WriteString("  right")
+		}
+	}
+	fmt.Fprintf(&sb, "  %d/%d", p.sel+1, len(p.shown))
+	if n := len(t.lines) - len(p.shown); n > 0 {
+		fmt.Fprintf(&sb, " (%d hidden)", n)
+	}
+	for _, name := range tuiLevels {
+		if t.hidden[name] {
+			sb.WriteString("  -" + format.LevelLabel(name))
+		}
+	}
+	if p.filter != nil {
+		sb.WriteString("  f:" + strings.TrimPrefix(p.filter.String(), "(?i)"))
+	}
+	if p.search != nil {
+		sb.WriteString("  /" + strings.TrimPrefix(p.search.String(), "(?i)"))
+	}
+	if t.message != "" {
+		sb.WriteString("  " + t.message)
+	}
+	sb.WriteString("  ? for keys")
+	return sb.String()
+}
+
+// appendVisible appends s cut to cols characters, escape sequences are kept
+// but not counted.
+func appendVisible(b, s []byte, cols int) []byte {
+	for len(s) > 0 {
+		if s[0] == '\033' {
+			n := escapeLen(s)
+			b = append(b, s[:n]...)
+			s = s[n:]
+			continue
+		}
+		_, size := utf8.DecodeRune(s)
+		if cols <= 0 {
+			s = s[size:]
+			continue
+		}
+		if s[0] == '\t' {
+			b = append(b, ' ')
+		} else if s[0] >= ' ' || size > 1 {
+			b = append(b, s[:size]...)
+		}
+		cols--
+		s = s[size:]
+	}
+	return b
+}
+
+// visibleLen returns the number of characters of s that are shown.
+func visibleLen(s []byte) int {
+	return utf8.RuneCount(stripColors(s))
+}
+
+// stripColors returns s without its escape sequences.
+func stripColors(s []byte) []byte {
+	if bytes.IndexByte(s, '\033') < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		if s[0] == '\033' {
+			s = s[escapeLen(s):]
+			continue
+		}
+		b = append(b, s[0])
+		s = s[1:]
+	}
+	return b
+}
+
+// escapeLen returns the length of the escape sequence at the start of s.
+// operating system commands, like the hyperlinks of -caller-link, end with
+// BEL or ESC \.
+func escapeLen(s []byte) int {
+	if len(s) > 2 && s[1] == 'O' {
+		return 3
+	}
+	if len(s) > 1 && s[1] == ']' {
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	if len(s) < 2 || s[1] != '[' {
+		return min(len(s), 2)
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// tuiWriter hands the lines written to the output, such as warnings and
+// notices, to the -tui.
+type tuiWriter struct {
+	partial []byte
+}
+
+func (w *tuiWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	if i := bytes.LastIndexByte(w.partial, '\n'); i >= 0 {
+		tui.add(w.partial[:i+1], "", nil)
+		w.partial = append(w.partial[:0], w.partial[i+1:]...)
+	}
+	return len(p), nil
+}
+
+// writeLine writes a displayed line, or hands it to the -tui along with the
+// source and entry it came from.  e is nil for lines that are not entries.
+func writeLine(b []byte, src string, e *Entry) {
+	flushDedup()
+	writeFormatted(b)
+	if tui == nil {
+		out.Write(b)
+		return
+	}
+	// what was written before the line has to be shown first.
+	out.Flush()
+	tui.add(b, src, e)
+}
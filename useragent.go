@@ -0,0 +1,155 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+)
+
+var (
+	parseUA = flag.String("parse-ua", "", "split the user agent in the given field into .browser, .os and .device fields")
+	hideUA  = flag.Bool("hide-ua", false, "hide the user agent split by -parse-ua")
+)
+
+// maxUserAgents is the number of parsed user agents remembered, there are
+// usually few distinct ones so most lines are a lookup.
+const maxUserAgents = 10000
+
+// userAgent is what a user agent string says about the client.
+type userAgent struct {
+	browser string
+	os      string
+	device  string
+}
+
+var userAgents = make(map[string]userAgent)
+
+// the browsers in the order they are checked, as the user agents of most
+// browsers also name the ones they are based on.
+var browserRules = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+).*Safari/`)},
+	{"IE", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+)`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+)`)},
+	{"Wget", regexp.MustCompile(`^Wget/(\d+)`)},
+	{"python-requests", regexp.MustCompile(`^python-requests/(\d+)`)},
+	{"Go", regexp.MustCompile(`^Go-http-client/(\d+)`)},
+}
+
+var (
+	botRe     = regexp.MustCompile(`(?i)([\w-]*(?:bot|crawler|spider|slurp))`)
+	windowsRe = regexp.MustCompile(`Windows NT (\d+\.\d+)`)
+	iosRe     = regexp.MustCompile(`(?:iPhone|CPU) OS (\d+(?:_\d+)*)`)
+	macRe     = regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)
+	androidRe = regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)
+)
+
+// the names of the windows versions.
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+// addUserAgent adds the fields of the -parse-ua user agent to the entry.
+func addUserAgent(e *Entry) {
+	s, ok := e.Fields[*parseUA].(string)
+	if !ok || s == "" {
+		return
+	}
+	ua, ok := userAgents[s]
+	if !ok {
+		if len(userAgents) >= maxUserAgents {
+			clear(userAgents)
+		}
+		ua = parseUserAgent(s)
+		userAgents[s] = ua
+	}
+
+	for _, f := range []struct{ key, val string }{
+		{".browser", ua.browser},
+		{".os", ua.os},
+		{".device", ua.device},
+	} {
+		if f.val != "" {
+			e.Fields[*parseUA+f.key] = f.val
+		}
+	}
+	if *hideUA {
+		delete(e.Fields, *parseUA)
+	}
+}
+
+// parseUserAgent picks the browser with its major version, the os and the
+// kind of device out of a user agent.
+func parseUserAgent(s string) userAgent {
+	var ua userAgent
+
+	if m := botRe.FindStringSubmatch(s); m != nil {
+		ua.browser = m[1]
+		ua.device = "bot"
+	} else {
+		for _, r := range browserRules {
+			if m := r.re.FindStringSubmatch(s); m != nil {
+				ua.browser = r.name + " " + m[1]
+				break
+			}
+		}
+	}
+
+	switch {
+	case strings.Contains(s, "Windows"):
+		ua.os = "Windows"
+		if m := windowsRe.FindStringSubmatch(s); m != nil {
+			if v, ok := windowsVersions[m[1]]; ok {
+				ua.os += " " + v
+			}
+		}
+	case strings.Contains(s, "iPhone") || strings.Contains(s, "iPad"):
+		ua.os = "iOS"
+		if m := iosRe.FindStringSubmatch(s); m != nil {
+			ua.os += " " + strings.ReplaceAll(m[1], "_", ".")
+		}
+	case strings.Contains(s, "Android"):
+		ua.os = "Android"
+		if m := androidRe.FindStringSubmatch(s); m != nil {
+			ua.os += " " + m[1]
+		}
+	case strings.Contains(s, "Mac OS X"):
+		ua.os = "macOS"
+		if m := macRe.FindStringSubmatch(s); m != nil {
+			ua.os += " " + strings.ReplaceAll(m[1], "_", ".")
+		}
+	case strings.Contains(s, "CrOS"):
+		ua.os = "ChromeOS"
+	case strings.Contains(s, "Linux"):
+		ua.os = "Linux"
+	}
+
+	if ua.device != "" {
+		return ua
+	}
+	switch {
+	case strings.Contains(s, "iPad") || strings.Contains(s, "Tablet") ||
+		(strings.Contains(s, "Android") && !strings.Contains(s, "Mobile")):
+		ua.device = "tablet"
+	case strings.Contains(s, "Mobile") || strings.Contains(s, "iPhone"):
+		ua.device = "mobile"
+	case ua.os != "":
+		ua.device = "desktop"
+	default:
+		ua.device = "other"
+	}
+	return ua
+}
@@ -0,0 +1,459 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cwbriscoe/glogv/format"
+)
+
+var whereExpr = flag.String("where", "", `only show entries matching an expression like 'level=="error" && http.status>=500 && duration>1s'`)
+
+// whereRule is the parsed -where expression, nil if it is not set.
+var whereRule exprNode
+
+// exprNode is a node of a -where expression that is true or false for an
+// entry.
+type exprNode interface {
+	eval(e *Entry) bool
+}
+
+type (
+	andNode struct{ left, right exprNode }
+	orNode  struct{ left, right exprNode }
+	notNode struct{ expr exprNode }
+
+	// cmpNode compares two operands, re is the expression of =~ and !~.
+	cmpNode struct {
+		left, right operand
+		op          string
+		re          *regexp.Regexp
+	}
+
+	// fieldNode is true if the field is set and not false, null or empty.
+	fieldNode struct{ path string }
+)
+
+// operand is a field, or a literal string, number, duration, bool or null.
+type operand struct {
+	path  string
+	value any
+}
+
+func (n *andNode) eval(e *Entry) bool { return n.left.eval(e) && n.right.eval(e) }
+func (n *orNode) eval(e *Entry) bool  { return n.left.eval(e) || n.right.eval(e) }
+func (n *notNode) eval(e *Entry) bool { return !n.expr.eval(e) }
+
+func (n *fieldNode) eval(e *Entry) bool {
+	v, ok := fieldValue(e, n.path)
+	if !ok {
+		return false
+	}
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	}
+	return true
+}
+
+// get returns the value of the operand for the entry and false if it is a
+// field that is not set.
+func (o operand) get(e *Entry) (any, bool) {
+	if o.path == "" {
+		return o.value, true
+	}
+	return fieldValue(e, o.path)
+}
+
+// fieldValue returns a field by its dotted path.  level, message, error and
+// time are the standard fields, nested objects are looked into when they
+// were kept by -expand.
+func fieldValue(e *Entry, path string) (any, bool) {
+	switch path {
+	case "level":
+		return e.Level, true
+	case "message":
+		return e.Message, true
+	case "error":
+		return e.Error, e.Error != ""
+	case "time":
+		return e.Time, !e.Time.IsZero()
+	}
+	if v, ok := e.Fields[path]; ok {
+		return v, true
+	}
+	var cur any = e.Fields
+	for _, k := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func (n *cmpNode) eval(e *Entry) bool {
+	a, ok := n.left.get(e)
+	if !ok {
+		return false
+	}
+	b, ok := n.right.get(e)
+	if !ok {
+		return false
+	}
+	if n.re != nil {
+		return n.re.MatchString(displayValue(a)) == (n.op == "=~")
+	}
+
+	// compare durations, times and numbers as such, anything else as text.
+	var c int
+	if x, y, ok := asDurations(a, b); ok {
+		c = compareOrdered(x, y)
+	} else if x, y, ok := asTimes(a, b); ok {
+		c = x.Compare(y)
+	} else if x, y, ok := asNumbers(a, b); ok {
+		c = compareOrdered(x, y)
+	} else {
+		c = strings.Compare(displayValue(a), displayValue(b))
+	}
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func compareOrdered[T int64 | float64](x, y T) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// asDurations converts both values to durations if either is a duration.  a
+// number is in -duration-unit and a string is a duration like 32ms.
+func asDurations(a, b any) (int64, int64, bool) {
+	_, da := a.(time.Duration)
+	_, db := b.(time.Duration)
+	if !da && !db {
+		return 0, 0, false
+	}
+	x, okx := toDuration(a)
+	y, oky := toDuration(b)
+	return int64(x), int64(y), okx && oky
+}
+
+func toDuration(v any) (time.Duration, bool) {
+	switch val := v.(type) {
+	case time.Duration:
+		return val, true
+	case float64:
+		return time.Duration(val * float64(*durationUnit)), true
+	case string:
+		d, err := time.ParseDuration(val)
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// asTimes converts both values to times if either is the time of the entry,
+// the other may be an RFC 3339 string or an epoch number.
+func asTimes(a, b any) (time.Time, time.Time, bool) {
+	_, ta := a.(time.Time)
+	_, tb := b.(time.Time)
+	if !ta && !tb {
+		return time.Time{}, time.Time{}, false
+	}
+	x, y := toExprTime(a), toExprTime(b)
+	return x, y, !x.IsZero() && !y.IsZero()
+}
+
+func toExprTime(v any) time.Time {
+	if t, ok := v.(time.Time); ok {
+		return t
+	}
+	return format.ToTime(v)
+}
+
+// asNumbers converts both values to numbers if they are numbers or numeric
+// strings.
+func asNumbers(a, b any) (float64, float64, bool) {
+	x, okx := toNumber(a)
+	y, oky := toNumber(b)
+	return x, y, okx && oky
+}
+
+// setWhere parses the -where expression.
+func setWhere() error {
+	whereRule = nil
+	if strings.TrimSpace(*whereExpr) == "" {
+		return nil
+	}
+	n, err := parseExpr(*whereExpr)
+	if err != nil {
+		return fmt.Errorf("-where: %w", err)
+	}
+	whereRule = n
+	return nil
+}
+
+// exprToken is a token of an expression, kind is one of the token kinds
+// below.
+type exprToken struct {
+	kind  byte
+	text  string
+	value any
+}
+
+const (
+	tokEnd     = 0
+	tokField   = 'f'
+	tokLiteral = 'l'
+	tokOp      = 'o'
+)
+
+// the operators of an expression, the longer ones first.
+var exprOps = []string{"&&", "||", "==", "!=", ">=", "<=", "=~", "!~", ">", "<", "!", "(", ")"}
+
+// lexExpr splits an expression into tokens.
+func lexExpr(s string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i+1)
+			}
+			text := s[i+1 : j]
+			if c == '"' {
+				var err error
+				if text, err = strconv.Unquote(s[i : j+1]); err != nil {
+					return nil, fmt.Errorf("bad string at %d", i+1)
+				}
+			}
+			toks = append(toks, exprToken{kind: tokLiteral, text: s[i : j+1], value: text})
+			i = j + 1
+			continue
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			j := i + 1
+			for j < len(s) && (isExprIdent(s[j]) || s[j] == '.') {
+				j++
+			}
+			text := s[i:j]
+			if n, ok := parseNumber(text); ok {
+				toks = append(toks, exprToken{kind: tokLiteral, text: text, value: n})
+			} else if d, err := time.ParseDuration(text); err == nil {
+				toks = append(toks, exprToken{kind: tokLiteral, text: text, value: d})
+			} else {
+				return nil, fmt.Errorf("%q is not a number or a duration", text)
+			}
+			i = j
+			continue
+		case isExprIdent(c):
+			j := i + 1
+			for j < len(s) && (isExprIdent(s[j]) || s[j] == '.' || s[j] == '-') {
+				j++
+			}
+			text := s[i:j]
+			switch text {
+			case "true", "false":
+				toks = append(toks, exprToken{kind: tokLiteral, text: text, value: text == "true"})
+			case "null":
+				toks = append(toks, exprToken{kind: tokLiteral, text: text})
+			default:
+				toks = append(toks, exprToken{kind: tokField, text: text})
+			}
+			i = j
+			continue
+		}
+		found := false
+		for _, op := range exprOps {
+			if strings.HasPrefix(s[i:], op) {
+				toks = append(toks, exprToken{kind: tokOp, text: op})
+				i += len(op)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unexpected %q at %d", s[i:i+1], i+1)
+		}
+	}
+	return toks, nil
+}
+
+func isExprIdent(c byte) bool {
+	return c == '_' || c == '@' || c == '$' || c >= '0' && c <= '9' || c < 0x80 && unicode.IsLetter(rune(c)) || c >= 0x80
+}
+
+// exprParser parses the tokens of an expression by recursive descent, with
+// ! binding tighter than && and && tighter than ||.
+type exprParser struct {
+	toks []exprToken
+	pos  int
+}
+
+func parseExpr(s string) (exprNode, error) {
+	toks, err := lexExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEnd {
+		return nil, fmt.Errorf("unexpected %s", t.text)
+	}
+	return n, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return exprToken{kind: tokEnd, text: "end of expression"}
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// isOp returns true and skips the next token if it is the operator.
+func (p *exprParser) isOp(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	left, err := p.and()
+	for err == nil && p.isOp("||") {
+		var right exprNode
+		if right, err = p.and(); err == nil {
+			left = &orNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	left, err := p.not()
+	for err == nil && p.isOp("&&") {
+		var right exprNode
+		if right, err = p.not(); err == nil {
+			left = &andNode{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) not() (exprNode, error) {
+	if p.isOp("!") {
+		n, err := p.not()
+		return &notNode{n}, err
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	if p.isOp("(") {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("expected ) instead of %s", p.peek().text)
+		}
+		return n, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp || !isCmpOp(t.text) {
+		if left.path == "" {
+			return nil, fmt.Errorf("expected a comparison after %s", p.toks[p.pos-1].text)
+		}
+		return &fieldNode{path: left.path}, nil
+	}
+	p.pos++
+
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	n := &cmpNode{left: left, op: t.text, right: right}
+	if t.text == "=~" || t.text == "!~" {
+		s, ok := right.value.(string)
+		if !ok || right.path != "" {
+			return nil, fmt.Errorf("%s needs a regular expression in quotes", t.text)
+		}
+		if n.re, err = regexp.Compile(s); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func isCmpOp(op string) bool {
+	switch op {
+	case "==", "!=", ">=", "<=", ">", "<", "=~", "!~":
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokField:
+		return operand{path: t.text}, nil
+	case tokLiteral:
+		return operand{value: t.value}, nil
+	}
+	return operand{}, fmt.Errorf("expected a field or a value instead of %s", t.text)
+}
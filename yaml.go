@@ -0,0 +1,206 @@
+// Copyright 2023 Christopher Briscoe.  All rights reserved.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a yaml file without its indentation and comment.
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+// parseYAML parses the subset of yaml used by small config files: maps and
+// lists nested by indentation, comments, quoted strings and [a, b] lists.
+// the values are returned as map[string]any, []any and string.
+func parseYAML(data []byte) (any, error) {
+	var lines []yamlLine
+	for i, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimRight(stripYAMLComment(l), " \t\r")
+		text := strings.TrimLeft(l, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can not be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{no: i + 1, indent: len(l) - len(text), text: text})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	v, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err == nil && next < len(lines) {
+		err = fmt.Errorf("line %d: bad indentation", lines[next].no)
+	}
+	return v, err
+}
+
+// parseYAMLBlock parses the map or list starting at line i with the given
+// indentation, and returns the index of the line after it.
+func parseYAMLBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if isYAMLItem(lines[i].text) {
+		var list []any
+		for i < len(lines) && lines[i].indent == indent && isYAMLItem(lines[i].text) {
+			item := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+			switch {
+			case item == "":
+				if i+1 == len(lines) || lines[i+1].indent <= indent {
+					list = append(list, nil)
+					i++
+					continue
+				}
+				v, next, err := parseYAMLBlock(lines, i+1, lines[i+1].indent)
+				if err != nil {
+					return nil, 0, err
+				}
+				list, i = append(list, v), next
+			case isYAMLKey(item):
+				// the item is a map which starts on the line of the dash.
+				lines[i] = yamlLine{no: lines[i].no, indent: indent + len(lines[i].text) - len(item), text: item}
+				v, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+				if err != nil {
+					return nil, 0, err
+				}
+				list, i = append(list, v), next
+			default:
+				v, err := yamlScalar(item)
+				if err != nil {
+					return nil, 0, fmt.Errorf("line %d: %w", lines[i].no, err)
+				}
+				list = append(list, v)
+				i++
+			}
+		}
+		return list, i, nil
+	}
+
+	m := make(map[string]any)
+	for i < len(lines) && lines[i].indent == indent && !isYAMLItem(lines[i].text) {
+		l := lines[i]
+		key, rest, ok := strings.Cut(l.text, ":")
+		if !ok || (rest != "" && rest[0] != ' ') {
+			return nil, 0, fmt.Errorf("line %d: expected key: value", l.no)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		rest = strings.TrimSpace(rest)
+		i++
+
+		if rest != "" {
+			v, err := yamlScalar(rest)
+			if err != nil {
+				return nil, 0, fmt.Errorf("line %d: %w", l.no, err)
+			}
+			m[key] = v
+			continue
+		}
+
+		// a nested block is indented, except that a list may start at the
+		// indentation of its key.
+		if i < len(lines) && (lines[i].indent > indent || (lines[i].indent == indent && isYAMLItem(lines[i].text))) {
+			v, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key], i = v, next
+			continue
+		}
+		m[key] = nil
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("line %d: bad indentation", lines[i].no)
+	}
+	return m, i, nil
+}
+
+func isYAMLItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+func isYAMLKey(s string) bool {
+	if s[0] == '"' || s[0] == '\'' || s[0] == '[' {
+		return false
+	}
+	key, rest, ok := strings.Cut(s, ":")
+	return ok && key != "" && (rest == "" || rest[0] == ' ')
+}
+
+// yamlScalar parses a value, which is a plain or quoted string or a [a, b]
+// list of them.
+func yamlScalar(s string) (any, error) {
+	switch s[0] {
+	case '"':
+		return strconv.Unquote(s)
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("unterminated list %s", s)
+		}
+		list := []any{}
+		for _, item := range splitYAMLList(s[1 : len(s)-1]) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := yamlScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	return s, nil
+}
+
+// splitYAMLList splits the items of a [a, b] list on the commas that are not
+// within quotes, so a quoted item like "message=a, b" is kept whole.
+func splitYAMLList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// stripYAMLComment removes a # comment that is not within quotes.
+func stripYAMLComment(l string) string {
+	var quote byte
+	for i := 0; i < len(l); i++ {
+		switch c := l[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || l[i-1] == ' ' || l[i-1] == '\t'):
+			return l[:i]
+		}
+	}
+	return l
+}